@@ -0,0 +1,490 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/downloader"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+	"github.com/thomas-introini/pocket-cli/outbox"
+	"github.com/thomas-introini/pocket-cli/syncer"
+	"github.com/thomas-introini/pocket-cli/utils"
+)
+
+var NotAuthenticatedErr = errors.New("not authenticated: run tasca without arguments to log in")
+
+const usage = `Usage: tasca [command] [arguments]
+
+Running tasca without a command opens the interactive interface.
+
+Commands:
+  add <url> [--title title] [--tags tag1,tag2]   save a new item
+  list [--tag tag] [--favorites] [--archived|--all]
+                                                 list unread saves
+  search <query>                                 search saves, e.g. tag:go is:fav "exact phrase"
+  archive <id>...                                archive saves
+  delete <id>...                                 delete saves
+  tag <id> [--add t] [--remove t] [--replace t] [--clear]
+                                                 edit the tags of a save
+  sync                                           send the queued actions and fetch changes from Pocket
+  outbox [--retry|--clear]                       list the actions not sent yet, retry or
+                                                 discard the failed ones
+  open <id>                                      open a save in the browser
+  download [--tag tag] [--favorites] [--archived|--all] [--workers n] [--retry-failed]
+                                                 download the articles of the saves to read
+                                                 them offline, failed ones are skipped
+                                                 unless --retry-failed is given
+  highlights                                     print the highlights of all the saves as Markdown
+  config init|show                               manage the config file
+`
+
+var commands = map[string]func(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error{
+	"add":        add,
+	"list":       list,
+	"search":     search,
+	"archive":    archive,
+	"delete":     remove,
+	"tag":        tag,
+	"sync":       sync,
+	"outbox":     pending,
+	"open":       open,
+	"download":   download,
+	"highlights": highlights,
+}
+
+func Run(client *lib.Client, user models.PocketUser, args []string) error {
+	if len(args) == 0 {
+		return nil
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		fmt.Print(usage)
+		return nil
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		return errors.New("unknown command " + name)
+	}
+	if user == models.NoUser {
+		return NotAuthenticatedErr
+	}
+	client.Retry.OnRetry = func(attempt, attempts int, err error) {
+		fmt.Fprintf(os.Stderr, "%s, retrying (%d/%d)\n", err, attempt, attempts)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := cmd(ctx, client, user, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Print(usage)
+		return nil
+	}
+	return err
+}
+
+func add(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	fs := newFlagSet("add")
+	title := fs.String("title", "", "title of the save")
+	tags := fs.String("tags", "", "comma separated list of tags")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("add: expected exactly one url")
+	}
+	save, err := outbox.Add(positional[0], *title, splitTags(*tags))
+	if err != nil {
+		return err
+	}
+	replayed := replay(ctx, client, user)
+	if added, ok := replayed.Added[save.Id]; ok {
+		fmt.Println("added", added.Id, added.Title())
+	} else if !rejected(replayed, save.Id) {
+		fmt.Println("queued", save.Id, save.Title())
+	}
+	return checkRejected("add", []string{save.Id}, replayed)
+}
+
+func list(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	fs := newFlagSet("list")
+	tag := fs.String("tag", "", "only show saves with this tag")
+	favorites := fs.Bool("favorites", false, "only show favorite saves")
+	archived := fs.Bool("archived", false, "show archived saves instead of unread ones")
+	all := fs.Bool("all", false, "show both unread and archived saves")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	saves, err := getSaves(*tag, *favorites, *archived, *all)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, save := range saves {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", save.Id, save.Title(), save.Url)
+	}
+	return w.Flush()
+}
+
+func download(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	fs := newFlagSet("download")
+	tag := fs.String("tag", "", "only download saves with this tag")
+	favorites := fs.Bool("favorites", false, "only download favorite saves")
+	archived := fs.Bool("archived", false, "download archived saves instead of unread ones")
+	all := fs.Bool("all", false, "download both unread and archived saves")
+	workers := fs.Int("workers", downloader.DefaultWorkers, "number of concurrent downloads")
+	retryFailed := fs.Bool("retry-failed", false, "download again the articles that failed before")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return errors.New("download: --workers must be at least 1")
+	}
+	saves, err := getSaves(*tag, *favorites, *archived, *all)
+	if err != nil {
+		return err
+	}
+	options := downloader.Options{Workers: *workers, RetryFailed: *retryFailed}
+	result, err := downloader.Download(ctx, client, saves, options, func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rdownloading %d / %d", done, total)
+		if done == total {
+			fmt.Fprintln(os.Stderr)
+		}
+	})
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "%s %s failed: %s\n", failure.Save.Id, failure.Save.Url, failure.Err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println("downloaded:", result)
+	if result.Skipped > 0 {
+		fmt.Println("run with --retry-failed to download the skipped articles again")
+	}
+	return nil
+}
+
+// getSaves returns the saves selected by the flags shared by list and download
+func getSaves(tag string, favorites, archived, all bool) ([]models.PocketSave, error) {
+	filter := db.UnreadSaves
+	if all {
+		filter = db.AllSaves
+	} else if archived {
+		filter = db.ArchivedSaves
+	}
+	var saves []models.PocketSave
+	var err error
+	if tag != "" {
+		saves, err = db.GetPocketSavesByTag(tag, filter)
+	} else {
+		saves, err = db.GetPocketSavesBy(filter)
+	}
+	if err != nil || !favorites {
+		return saves, err
+	}
+	favs := make([]models.PocketSave, 0, len(saves))
+	for _, save := range saves {
+		if save.Favorite {
+			favs = append(favs, save)
+		}
+	}
+	return favs, nil
+}
+
+func search(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	if len(args) == 0 {
+		return errors.New("search: expected a query")
+	}
+	results, err := db.SearchSaves(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+	if !db.FullTextSearch() {
+		fmt.Fprintln(os.Stderr, "full-text search is off, results are not ranked: build tasca with -tags sqlite_fts5")
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Save.Id, result.Save.Title(), result.Save.Url)
+	}
+	return w.Flush()
+}
+
+func archive(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	return modify(ctx, client, user, "archive", lib.ActionArchive, args)
+}
+
+func remove(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	return modify(ctx, client, user, "delete", lib.ActionDelete, args)
+}
+
+// modify applies the action to the saves locally and sends it to Pocket
+func modify(ctx context.Context, client *lib.Client, user models.PocketUser, name string, action string, args []string) error {
+	ids, err := parseIds(name, args)
+	if err != nil {
+		return err
+	}
+	saves, err := getSavesById(ids)
+	if err != nil {
+		return err
+	}
+	if _, err = outbox.Modify(action, saves...); err != nil {
+		return err
+	}
+	replayed := replay(ctx, client, user)
+	for _, id := range ids {
+		if !rejected(replayed, id) {
+			fmt.Println(name, id)
+		}
+	}
+	return checkRejected(name, ids, replayed)
+}
+
+func tag(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	fs := newFlagSet("tag")
+	add := fs.String("add", "", "comma separated list of tags to add")
+	rm := fs.String("remove", "", "comma separated list of tags to remove")
+	replace := fs.String("replace", "", "comma separated list of tags replacing the current ones")
+	clear := fs.Bool("clear", false, "remove all tags")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("tag: expected exactly one id")
+	}
+	save, err := db.GetPocketSave(positional[0])
+	if err != nil {
+		return err
+	}
+
+	tags := slices.Clone(save.Tags)
+	if *clear {
+		tags = []string{}
+	}
+	if *replace != "" {
+		tags = splitTags(*replace)
+	}
+	if *add != "" {
+		for _, t := range splitTags(*add) {
+			if !slices.Contains(tags, t) {
+				tags = append(tags, t)
+			}
+		}
+	}
+	if *rm != "" {
+		removed := splitTags(*rm)
+		kept := make([]string, 0, len(tags))
+		for _, t := range tags {
+			if !slices.Contains(removed, t) {
+				kept = append(kept, t)
+			}
+		}
+		tags = kept
+	}
+	if !*clear && *replace == "" && *add == "" && *rm == "" {
+		fmt.Println(strings.Join(tags, ","))
+		return nil
+	}
+	if *clear || *replace != "" {
+		// the tags are replaced as a whole, the actions queued for the save before apply first
+		_, err = outbox.ReplaceTags(save, tags)
+	} else {
+		_, err = outbox.EditTags(save, tags)
+	}
+	if err != nil {
+		return err
+	}
+	replayed := replay(ctx, client, user)
+	if err = checkRejected("tag", []string{save.Id}, replayed); err != nil {
+		return err
+	}
+	fmt.Println(strings.Join(tags, ","))
+	return nil
+}
+
+func sync(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	if _, err := parseArgs(newFlagSet("sync"), args); err != nil {
+		return err
+	}
+	replayed := outbox.Replay(ctx, client, user.AccessToken)
+	if replayed.Sent > 0 {
+		fmt.Println("sent", replayed.Sent, "actions")
+	}
+	for _, failed := range replayed.Failed {
+		fmt.Fprintf(os.Stderr, "%s %s failed: %s\n", failed.Action, failed.SaveId, failed.LastError)
+	}
+	if replayed.Err != nil {
+		return fmt.Errorf("%d actions not sent: %w", replayed.Pending, replayed.Err)
+	}
+	result, err := syncer.Sync(ctx, client, user.AccessToken, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Println("synced:", result)
+	return nil
+}
+
+func pending(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	fs := newFlagSet("outbox")
+	retry := fs.Bool("retry", false, "queue the failed actions again")
+	clear := fs.Bool("clear", false, "discard the failed actions")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *retry && *clear {
+		return errors.New("outbox: --retry and --clear are mutually exclusive")
+	}
+	if *retry {
+		return db.RetryFailedActions()
+	}
+	if *clear {
+		return db.ClearFailedActions()
+	}
+	queued, err := db.GetQueuedActions()
+	if err != nil {
+		return err
+	}
+	failed, err := db.GetFailedActions()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, action := range append(queued, failed...) {
+		state := "pending"
+		if action.Failed {
+			state = "failed"
+		}
+		created := time.Unix(action.CreatedOn, 0).Format("2006-01-02 15:04")
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", created, action.Action, action.SaveId, state, action.LastError)
+	}
+	return w.Flush()
+}
+
+func open(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	ids, err := parseIds("open", args)
+	if err != nil {
+		return err
+	}
+	if len(ids) != 1 {
+		return errors.New("open: expected exactly one id")
+	}
+	save, err := db.GetPocketSave(ids[0])
+	if err != nil {
+		return err
+	}
+	return utils.OpenInBrowser(save.Url)
+}
+
+func highlights(ctx context.Context, client *lib.Client, user models.PocketUser, args []string) error {
+	if _, err := parseArgs(newFlagSet("highlights"), args); err != nil {
+		return err
+	}
+	list, err := db.GetAllHighlights()
+	if err != nil {
+		return err
+	}
+	fmt.Print(utils.HighlightsMarkdown(list))
+	return nil
+}
+
+// replay sends the queued actions to Pocket, the ones that cannot be sent now
+// stay queued and are sent by the next sync
+func replay(ctx context.Context, client *lib.Client, user models.PocketUser) outbox.ReplayResult {
+	replayed := outbox.Replay(ctx, client, user.AccessToken)
+	for _, failed := range replayed.Failed {
+		fmt.Fprintf(os.Stderr, "%s %s failed: %s\n", failed.Action, failed.SaveId, failed.LastError)
+	}
+	if replayed.Err != nil {
+		fmt.Fprintf(os.Stderr, "%d actions queued, run tasca sync to send them: %s\n", replayed.Pending, replayed.Err)
+	}
+	return replayed
+}
+
+// rejected tells whether Pocket rejected an action for the save
+func rejected(replayed outbox.ReplayResult, id string) bool {
+	return slices.ContainsFunc(replayed.Failed, func(failed db.PendingAction) bool {
+		return failed.SaveId == id
+	})
+}
+
+func checkRejected(action string, ids []string, replayed outbox.ReplayResult) error {
+	count := 0
+	for _, id := range ids {
+		if rejected(replayed, id) {
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%s: %d of %d actions rejected by Pocket", action, count, len(ids))
+	}
+	return nil
+}
+
+func getSavesById(ids []string) ([]models.PocketSave, error) {
+	saves := make([]models.PocketSave, 0, len(ids))
+	for _, id := range ids {
+		save, err := db.GetPocketSave(id)
+		if errors.Is(err, db.NoSaveErr) {
+			return nil, errors.New("save " + id + " not found, run tasca sync to fetch the latest saves")
+		} else if err != nil {
+			return nil, err
+		}
+		saves = append(saves, save)
+	}
+	return saves, nil
+}
+
+func parseIds(name string, args []string) ([]string, error) {
+	ids, err := parseArgs(newFlagSet(name), args)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New(name + ": expected at least one id")
+	}
+	return ids, nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("tasca "+name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseArgs allows flags to appear after positional arguments,
+// e.g. `tasca add <url> --title title`
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func splitTags(tags string) []string {
+	list := make([]string, 0)
+	for _, t := range strings.Split(tags, ",") {
+		if t = strings.TrimSpace(t); t != "" && !slices.Contains(list, t) {
+			list = append(list, t)
+		}
+	}
+	return list
+}
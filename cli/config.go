@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/thomas-introini/pocket-cli/config"
+)
+
+const configUsage = `Usage: tasca config <command>
+
+Commands:
+  init [--force]   create the config file with the default values
+  show             print the config file path and the effective configuration
+`
+
+// RunConfig handles `tasca config`, which must work before the consumer key
+// is configured and the database is available
+func RunConfig(args []string) error {
+	if len(args) == 0 {
+		fmt.Print(configUsage)
+		return nil
+	}
+	switch args[0] {
+	case "init":
+		fs := newFlagSet("config init")
+		force := fs.Bool("force", false, "overwrite an existing config file")
+		if _, err := parseArgs(fs, args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				fmt.Print(configUsage)
+				return nil
+			}
+			return err
+		}
+		path, err := config.WriteDefault(*force)
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", path)
+		return nil
+	case "show":
+		fmt.Println("# " + config.Path())
+		fmt.Print(config.GetConfig())
+		return nil
+	case "help", "-h", "--help":
+		fmt.Print(configUsage)
+		return nil
+	default:
+		return errors.New("unknown config command " + args[0])
+	}
+}
@@ -1,6 +1,9 @@
 package commands
 
-import tea "github.com/charmbracelet/bubbletea"
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thomas-introini/pocket-cli/models"
+)
 
 type SetLabelMsg struct {
 	Show    bool
@@ -10,3 +13,15 @@ type SetLabelMsg struct {
 func SetLabelCmd(msg string) tea.Cmd {
 	return func() tea.Msg { return SetLabelMsg{msg != "", msg} }
 }
+
+type SavesModifiedMsg struct {
+	Action string
+	Saves  []models.PocketSave
+	Err    error
+}
+
+// ReadProgressMsg is sent when the reading progress of a save is stored
+type ReadProgressMsg struct {
+	SaveId   string
+	Progress int
+}
@@ -1,17 +1,191 @@
 package config
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultDBPath        = "~/.cache/pocket-cli-go/cache.db"
+	DefaultTheme         = "red"
+	DefaultSyncInterval  = 15 * time.Minute
+	DefaultRetryAttempts = 5
+)
+
 type Config struct {
-	PocketConsumerKey string
+	PocketConsumerKey string              `yaml:"consumer_key"`
+	DBPath            string              `yaml:"db_path"`
+	AuthCallbackPort  int                 `yaml:"auth_callback_port"`
+	Theme             string              `yaml:"theme"`
+	SyncInterval      time.Duration       `yaml:"sync_interval"`
+	RetryAttempts     int                 `yaml:"retry_attempts"`
+	PocketURL         string              `yaml:"pocket_url,omitempty"`
+	Keybindings       map[string][]string `yaml:"keybindings,omitempty"`
 }
 
 var instance *Config
 
-func InitConfig(consumerKey string) {
-	instance = &Config{
-		PocketConsumerKey: consumerKey,
-	}
+func InitConfig(config Config) {
+	instance = &config
 }
 
 func GetConfig() Config {
 	return *instance
 }
+
+func Default() Config {
+	return Config{
+		DBPath:        DefaultDBPath,
+		Theme:         DefaultTheme,
+		SyncInterval:  DefaultSyncInterval,
+		RetryAttempts: DefaultRetryAttempts,
+	}
+}
+
+func Path() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(dir, "tasca", "config.yaml")
+}
+
+// Load reads the config file, if present, and applies the environment variables on top of it
+func Load() (Config, error) {
+	config := Default()
+	content, err := os.ReadFile(Path())
+	if err != nil && !os.IsNotExist(err) {
+		return config, err
+	}
+	if err == nil {
+		if err = yaml.Unmarshal(content, &config); err != nil {
+			return config, fmt.Errorf("%s: %w", Path(), err)
+		}
+	}
+	if v := os.Getenv("POCKET_CONSUMER_KEY"); v != "" {
+		config.PocketConsumerKey = v
+	}
+	if v := os.Getenv("TASCA_DB_PATH"); v != "" {
+		config.DBPath = v
+	}
+	if v := os.Getenv("TASCA_AUTH_CALLBACK_PORT"); v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return config, errors.New("TASCA_AUTH_CALLBACK_PORT: invalid port " + v)
+		}
+		config.AuthCallbackPort = port
+	}
+	if v := os.Getenv("TASCA_THEME"); v != "" {
+		config.Theme = v
+	}
+	if v := os.Getenv("TASCA_SYNC_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return config, errors.New("TASCA_SYNC_INTERVAL: invalid duration " + v)
+		}
+		config.SyncInterval = interval
+	}
+	if v := os.Getenv("TASCA_RETRY_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil {
+			return config, errors.New("TASCA_RETRY_ATTEMPTS: invalid number " + v)
+		}
+		config.RetryAttempts = attempts
+	}
+	if v := os.Getenv("TASCA_POCKET_URL"); v != "" {
+		config.PocketURL = v
+	}
+	if config.SyncInterval < 0 {
+		return config, fmt.Errorf("invalid sync interval %s", config.SyncInterval)
+	}
+	if config.RetryAttempts < 1 {
+		return config, fmt.Errorf("invalid retry attempts %d, it must be at least 1", config.RetryAttempts)
+	}
+	if config.AuthCallbackPort < 0 || config.AuthCallbackPort > 65535 {
+		return config, fmt.Errorf("invalid auth callback port %d", config.AuthCallbackPort)
+	}
+	config.DBPath = expandHome(config.DBPath)
+	return config, nil
+}
+
+const template = `# Pocket consumer key, see http://getpocket.com/developer/apps/new
+# Can be overridden with the POCKET_CONSUMER_KEY environment variable
+consumer_key: %q
+
+# Path of the local cache database (TASCA_DB_PATH)
+db_path: %q
+
+# Port of the local server receiving the authentication callback,
+# 0 picks a random port (TASCA_AUTH_CALLBACK_PORT)
+auth_callback_port: 0
+
+# One of red, blue, green, purple, orange or a hex color like "#ef4056" (TASCA_THEME)
+theme: %q
+
+# How often saves are synced in the background while tasca is open,
+# 0 disables it (TASCA_SYNC_INTERVAL)
+sync_interval: %s
+
+# How many times a request failed because of a network or server error is attempted,
+# 1 disables retries (TASCA_RETRY_ATTEMPTS)
+retry_attempts: %d
+
+# Override the default key of an action, e.g.
+# keybindings:
+#   archive: ["A"]
+#   favorite: ["F", "f"]
+#   delete: ["D"]
+#   add: ["a"]
+#   refresh: ["R"]
+#   open: ["o"]
+#   manage_tags: ["T"]
+#   edit_tags: ["t"]
+#   get_content: ["g"]
+#   refetch_content: ["G"]
+#   search: ["s"]
+#   download: ["O"]
+#   highlight: ["v"]
+#   highlights: ["H"]
+#   find: ["/"]
+#   find_next: ["n"]
+#   find_previous: ["N"]
+#   links: ["l"]
+#   next_view: ["tab"]
+#   prev_view: ["shift+tab"]
+`
+
+// WriteDefault creates the config file with the default values and the
+// consumer key found in the environment, if any
+func WriteDefault(force bool) (string, error) {
+	path := Path()
+	if _, err := os.Stat(path); err == nil && !force {
+		return path, errors.New(path + " already exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return path, err
+	}
+	content := fmt.Sprintf(template, os.Getenv("POCKET_CONSUMER_KEY"), DefaultDBPath, DefaultTheme, DefaultSyncInterval, DefaultRetryAttempts)
+	return path, os.WriteFile(path, []byte(content), 0o600)
+}
+
+func (c Config) String() string {
+	content, err := yaml.Marshal(c)
+	if err != nil {
+		return err.Error()
+	}
+	return string(content)
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		return filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	return path
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+var NoArticleErr = errors.New("article not downloaded")
+
+// GetArticle returns the article downloaded for the save, NoArticleErr if it was
+// never downloaded or it was downloaded from a different url
+func GetArticle(saveId, url string) (models.Article, error) {
+	var article models.Article
+	err := DB.QueryRow(`
+		SELECT save_id, url, text, html, byline, site_name, fetched_on
+		  FROM article
+		 WHERE save_id = ?
+		   AND url = ?`,
+		saveId,
+		url,
+	).Scan(&article.SaveId, &article.Url, &article.Text, &article.Html, &article.Byline, &article.SiteName, &article.FetchedOn)
+	if err == sql.ErrNoRows {
+		return article, NoArticleErr
+	}
+	return article, err
+}
+
+// SaveArticle stores the article, replacing the one already downloaded for the save
+func SaveArticle(article models.Article) error {
+	_, err := DB.Exec(`
+		INSERT INTO article(save_id, url, text, html, byline, site_name, fetched_on)
+		VALUES (?,?,?,?,?,?,?)
+		ON CONFLICT(save_id) DO
+		UPDATE SET
+			   url = excluded.url,
+			  text = excluded.text,
+			  html = excluded.html,
+			byline = excluded.byline,
+		 site_name = excluded.site_name,
+		fetched_on = excluded.fetched_on`,
+		article.SaveId,
+		article.Url,
+		article.Text,
+		article.Html,
+		article.Byline,
+		article.SiteName,
+		article.FetchedOn,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err = DB.Exec("DELETE FROM article_failure WHERE save_id = ?", article.SaveId); err != nil {
+		return err
+	}
+	return IndexArticleContent(article.SaveId, article.Text)
+}
+
+// ArticleFailure is a save whose article could not be downloaded
+type ArticleFailure struct {
+	SaveId   string
+	Url      string
+	Error    string
+	Attempts int
+	FailedOn int64
+}
+
+// GetArticlesToDownload returns, among the saves, the ones without a downloaded article
+// and the failures of the previous downloads, keyed by save id
+func GetArticlesToDownload(saves []models.PocketSave) (missing []models.PocketSave, failures map[string]ArticleFailure, err error) {
+	missing = make([]models.PocketSave, 0)
+	failures = make(map[string]ArticleFailure)
+	for _, save := range saves {
+		var exists bool
+		err = DB.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM article WHERE save_id = ? AND url = ?)",
+			save.Id,
+			save.Url,
+		).Scan(&exists)
+		if err != nil {
+			return
+		}
+		if exists {
+			continue
+		}
+		missing = append(missing, save)
+		var failure ArticleFailure
+		err = DB.QueryRow(`
+			SELECT save_id, url, error, attempts, failed_on
+			  FROM article_failure
+			 WHERE save_id = ?
+			   AND url = ?`,
+			save.Id,
+			save.Url,
+		).Scan(&failure.SaveId, &failure.Url, &failure.Error, &failure.Attempts, &failure.FailedOn)
+		if err == sql.ErrNoRows {
+			err = nil
+			continue
+		} else if err != nil {
+			return
+		}
+		failures[save.Id] = failure
+	}
+	return
+}
+
+// FailArticle records that the article of the save could not be downloaded from url
+func FailArticle(saveId, url string, reason error) error {
+	_, err := DB.Exec(`
+		INSERT INTO article_failure(save_id, url, error, failed_on)
+		VALUES (?,?,?,?)
+		ON CONFLICT(save_id) DO
+		UPDATE SET
+			 attempts = CASE WHEN url = excluded.url THEN attempts + 1 ELSE 1 END,
+			      url = excluded.url,
+			    error = excluded.error,
+			failed_on = excluded.failed_on`,
+		saveId,
+		url,
+		reason.Error(),
+		time.Now().Unix(),
+	)
+	return err
+}
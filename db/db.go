@@ -3,61 +3,44 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/thomas-introini/pocket-cli/config"
 	"github.com/thomas-introini/pocket-cli/models"
 )
 
-const DB_PATH = ".cache/pocket-cli-go/cache.db"
-
 var NoUserErr = errors.New("user: no logged user found")
 var NoSavesErr = errors.New("user: no saves found")
+var NoSaveErr = errors.New("save: not found")
 
 var DB *sql.DB
 
 func ConnectDB() error {
-	_, err := os.Stat(os.Getenv("HOME") + "/" + DB_PATH)
+	dbPath := config.GetConfig().DBPath
+	_, err := os.Stat(dbPath)
 	if os.IsNotExist(err) {
-		err = os.MkdirAll(os.Getenv("HOME")+"/"+filepath.Dir(DB_PATH), os.ModePerm)
+		err = os.MkdirAll(filepath.Dir(dbPath), os.ModePerm)
 		if err != nil {
 			return err
 		}
 	}
-	db, err := sql.Open("sqlite3", os.Getenv("HOME")+"/"+DB_PATH)
+	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on")
 	if err != nil {
 		return err
 	}
-	_, err = os.Stat(os.Getenv("HOME") + "/" + DB_PATH)
-	if os.IsNotExist(err) {
-		_, err = db.Exec(`
-			CREATE TABLE user (
-				username         TEXT PRIMARY KEY,
-				access_token     TEXT,
-				saves_updated_on INTEGER(8)
-			)`)
-		if err != nil {
-			return err
-		}
-		_, err = db.Exec(`
-			CREATE TABLE save (
-				id           TEXT PRIMARY KEY,
-				title        TEXT,
-				url          TEXT NOT NULL,
-				description  TEXT,
-				status       INTEGER(1),
-				favorite     INTEGER(1),
-			    tags         TEXT,
-				time_to_read INTEGER,
-				added_on     INTEGER(8),
-				updated_on   INTEGER(8)
-			)`)
-		if err != nil {
-			return err
-		}
+	if err = migrate(db); err != nil {
+		db.Close()
+		return err
+	}
+	if err = openSearchIndex(db); err != nil {
+		db.Close()
+		return err
 	}
 	DB = db
 	return nil
@@ -86,7 +69,7 @@ func GetLoggedUser() (user models.PocketUser, err error) {
 	}
 
 	if i.Valid {
-		i.Scan(&updatedOn)
+		updatedOn = i.Int32
 	}
 
 	user = models.PocketUser{
@@ -98,12 +81,51 @@ func GetLoggedUser() (user models.PocketUser, err error) {
 	return
 }
 
-func GetPocketSaves() (list []models.PocketSave, err error) {
+type SavesFilter int
+
+const (
+	UnreadSaves SavesFilter = iota
+	ArchivedSaves
+	FavoriteSaves
+	AllSaves
+)
+
+var savesFilterWhere = map[SavesFilter]string{
+	UnreadSaves:   "status = 0",
+	ArchivedSaves: "status = 1",
+	FavoriteSaves: "status <> 2 AND favorite = 1",
+	AllSaves:      "status <> 2",
+}
+
+// Matches reports whether the save is part of the saves returned for the filter
+func (f SavesFilter) Matches(save models.PocketSave) bool {
+	switch f {
+	case UnreadSaves:
+		return save.Status == models.StatusOK
+	case ArchivedSaves:
+		return save.Status == models.StatusArchived
+	case FavoriteSaves:
+		return save.Status != models.StatusDeleted && save.Favorite
+	default:
+		return save.Status != models.StatusDeleted
+	}
+}
+
+func GetPocketSaves() ([]models.PocketSave, error) {
+	return GetPocketSavesBy(UnreadSaves)
+}
+
+func GetPocketSavesBy(filter SavesFilter) (list []models.PocketSave, err error) {
 	list = make([]models.PocketSave, 0)
+	where, ok := savesFilterWhere[filter]
+	if !ok {
+		err = fmt.Errorf("saves: unknown filter %d", filter)
+		return
+	}
 	rows, err := DB.Query(`
-		SELECT id, title, url, description, time_to_read, status, favorite, tags, added_on, updated_on
+		SELECT ` + saveColumns("") + `
 		  FROM save
-		 WHERE status = 0
+		 WHERE ` + where + `
 		 ORDER BY added_on DESC`,
 	)
 	if err == sql.ErrNoRows {
@@ -112,51 +134,165 @@ func GetPocketSaves() (list []models.PocketSave, err error) {
 	} else if err != nil {
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var (
-			id         string
-			title      string
-			url        string
-			desc       string
-			timeToRead uint16
-			status     uint8
-			favorite   uint8
-			tags       string
-			addedOn    uint32
-			updatedOn  uint32
-		)
-		if err = rows.Scan(
-			&id,
-			&title,
-			&url,
-			&desc,
-			&timeToRead,
-			&status,
-			&favorite,
-			&tags,
-			&addedOn,
-			&updatedOn,
-		); err != nil {
+		var save models.PocketSave
+		if save, err = scanSave(rows); err != nil {
 			return
 		}
-		save := models.PocketSave{
-			Id:              id,
-			SaveTitle:       title,
-			Url:             url,
-			SaveDescription: desc,
-			TimeToRead:      timeToRead,
-			Status:          status,
-			Favorite:        favorite == 1,
-			Tags:            tags,
-			AddedOn:         addedOn,
-			UpdatedOn:       updatedOn,
-		}
 		list = append(list, save)
 	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if err = attachTags(list); err != nil {
+		return
+	}
+	err = attachReadProgress(list)
 	return
 }
 
+// GetSyncCursor returns the Pocket time of the last sync, 0 if saves were never synced
+func GetSyncCursor() (since int64, err error) {
+	err = DB.QueryRow("SELECT COALESCE(saves_updated_on, 0) FROM user LIMIT 1").Scan(&since)
+	if err == sql.ErrNoRows {
+		err = NoUserErr
+	}
+	return
+}
+
+func GetExistingSaveIds(ids ...string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	for _, id := range ids {
+		var exists bool
+		err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM save WHERE id = ?)", id).Scan(&exists)
+		if err != nil {
+			return existing, err
+		}
+		if exists {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
+
+func HasSaves() (bool, error) {
+	var exists bool
+	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM save)").Scan(&exists)
+	return exists, err
+}
+
+func GetPocketSave(id string) (models.PocketSave, error) {
+	return getPocketSave(DB.QueryRow(`
+		SELECT `+saveColumns("")+`
+		  FROM save
+		 WHERE id = ?`,
+		id,
+	))
+}
+
+// GetPocketSaveByUrl returns the save of the url, the last added if it was saved more than once
+func GetPocketSaveByUrl(url string) (models.PocketSave, error) {
+	return getPocketSave(DB.QueryRow(`
+		SELECT `+saveColumns("")+`
+		  FROM save
+		 WHERE url = ? OR resolved_url = ?
+		 ORDER BY added_on DESC
+		 LIMIT 1`,
+		url,
+		url,
+	))
+}
+
+func getPocketSave(row *sql.Row) (models.PocketSave, error) {
+	save, err := scanSave(row)
+	if err == sql.ErrNoRows {
+		return save, NoSaveErr
+	} else if err != nil {
+		return save, err
+	}
+	if save.Tags, err = getSaveTags(save.Id); err != nil {
+		return save, err
+	}
+	save.ReadProgress, err = GetReadProgress(save.Id)
+	return save, err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+var saveColumnNames = []string{
+	"id", "title", "url", "description", "time_to_read", "status", "favorite", "added_on", "updated_on",
+	"resolved_url", "word_count", "lang", "top_image_url", "authors", "images", "domain", "is_article", "has_video", "listen_duration",
+}
+
+// saveColumns returns the columns read by scanSave, prefixed by the table alias if any
+func saveColumns(alias string) string {
+	if alias == "" {
+		return strings.Join(saveColumnNames, ", ")
+	}
+	return alias + "." + strings.Join(saveColumnNames, ", "+alias+".")
+}
+
+// scanSave reads the saveColumns of a row, followed by the extra columns if any
+func scanSave(row scanner, extra ...any) (models.PocketSave, error) {
+	var (
+		save      models.PocketSave
+		favorite  uint8
+		isArticle uint8
+		hasVideo  uint8
+		authors   string
+		images    string
+	)
+	dest := []any{
+		&save.Id,
+		&save.SaveTitle,
+		&save.Url,
+		&save.SaveDescription,
+		&save.TimeToRead,
+		&save.Status,
+		&favorite,
+		&save.AddedOn,
+		&save.UpdatedOn,
+		&save.ResolvedUrl,
+		&save.WordCount,
+		&save.Lang,
+		&save.TopImageUrl,
+		&authors,
+		&images,
+		&save.Domain,
+		&isArticle,
+		&hasVideo,
+		&save.ListenDuration,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return models.PocketSave{}, err
+	}
+	save.Favorite = favorite == 1
+	save.IsArticle = isArticle == 1
+	save.HasVideo = hasVideo == 1
+	save.Tags = []string{}
+	save.Authors = decodeList(authors)
+	save.Images = decodeList(images)
+	return save, nil
+}
+
+func encodeList(list []string) string {
+	if len(list) == 0 {
+		return "[]"
+	}
+	content, _ := json.Marshal(list)
+	return string(content)
+}
+
+func decodeList(content string) []string {
+	list := make([]string, 0)
+	json.Unmarshal([]byte(content), &list)
+	return list
+}
+
 func SaveUser(accessToken, username string) (models.PocketUser, error) {
 	current, err := GetLoggedUser()
 	if err != nil {
@@ -165,7 +301,7 @@ func SaveUser(accessToken, username string) (models.PocketUser, error) {
 	if current == models.NoUser {
 		_, err = DB.Exec("INSERT INTO user(access_token, username) VALUES (?,?)", accessToken, username)
 	} else {
-		_, err = DB.Exec("UPDATE user SET access_token = ? WHERE user = ?", accessToken, username)
+		_, err = DB.Exec("UPDATE user SET access_token = ? WHERE username = ?", accessToken, username)
 	}
 	if err != nil {
 		fmt.Println("could not save user", err)
@@ -180,23 +316,62 @@ func SaveUser(accessToken, username string) (models.PocketUser, error) {
 	return user, err
 }
 
-func InsertSaves(since float64, saves []models.PocketSave) ([]models.PocketSave, error) {
-	ret := make([]models.PocketSave, 0)
+// InsertSaves applies the saves fetched from Pocket, removing the deleted ones
+func InsertSaves(saves []models.PocketSave) error {
 	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
 	if err != nil {
-		defer tx.Rollback()
-		return ret, err
+		return err
 	}
 	for _, save := range saves {
 		if save.Status == models.StatusDeleted {
-			_, err = tx.Exec(
-				"DELETE FROM save where id = ?",
-				save.Id,
-			)
+			err = unindexSave(tx, save.Id)
+			if err == nil {
+				_, err = tx.Exec(
+					"DELETE FROM save where id = ?",
+					save.Id,
+				)
+			}
 		} else {
-			_, err = tx.Exec(
-				`INSERT INTO save(id, title, url, description, time_to_read, status, favorite, tags, added_on, updated_on)
-			 VALUES(?,?,?,?,?,?,?,?,?,?)
+			err = upsertSave(tx, save)
+		}
+		if err != nil {
+			defer tx.Rollback()
+			return err
+		}
+	}
+	if err = deleteUnusedTags(tx); err != nil {
+		defer tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetSyncCursor stores the time to fetch the changes from on the next sync
+func SetSyncCursor(since int64) error {
+	_, err := DB.Exec("UPDATE user SET saves_updated_on = ?", since)
+	return err
+}
+
+func UpsertSaves(saves ...models.PocketSave) error {
+	return applyChange(UpsertSavesChange(saves...))
+}
+
+// UpsertSavesChange stores the saves, replacing the ones with the same id
+func UpsertSavesChange(saves ...models.PocketSave) LocalChange {
+	return func(tx *sql.Tx) error {
+		for _, save := range saves {
+			if err := upsertSave(tx, save); err != nil {
+				return err
+			}
+		}
+		return deleteUnusedTags(tx)
+	}
+}
+
+func upsertSave(tx *sql.Tx, save models.PocketSave) error {
+	_, err := tx.Exec(
+		`INSERT INTO save(`+saveColumns("")+`)
+			 VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)
 			 ON CONFLICT(id) DO
 			 UPDATE SET
 			  title = excluded.title,
@@ -205,36 +380,92 @@ func InsertSaves(since float64, saves []models.PocketSave) ([]models.PocketSave,
 	   time_to_read = excluded.time_to_read,
 			 status = excluded.status,
 		   favorite = excluded.favorite,
-			   tags = excluded.tags,
 		   added_on = excluded.added_on,
-		 updated_on = excluded.updated_on`,
-				save.Id,
-				save.SaveTitle,
-				save.Url,
-				save.SaveDescription,
-				save.TimeToRead,
-				save.Status,
-				save.Favorite,
-				save.Tags,
-				save.AddedOn,
-				save.UpdatedOn,
-			)
-		}
-		if err != nil {
-			defer tx.Rollback()
-			return ret, err
-		}
-		ret = append(ret, save)
-	}
-	_, err = tx.Exec("UPDATE user SET saves_updated_on = ?", since)
+		 updated_on = excluded.updated_on,
+	   resolved_url = excluded.resolved_url,
+		 word_count = excluded.word_count,
+			   lang = excluded.lang,
+	  top_image_url = excluded.top_image_url,
+			authors = excluded.authors,
+			 images = excluded.images,
+			 domain = excluded.domain,
+		 is_article = excluded.is_article,
+		  has_video = excluded.has_video,
+	listen_duration = excluded.listen_duration`,
+		save.Id,
+		save.SaveTitle,
+		save.Url,
+		save.SaveDescription,
+		save.TimeToRead,
+		save.Status,
+		save.Favorite,
+		save.AddedOn,
+		save.UpdatedOn,
+		save.ResolvedUrl,
+		save.WordCount,
+		save.Lang,
+		save.TopImageUrl,
+		encodeList(save.Authors),
+		encodeList(save.Images),
+		save.Domain,
+		save.IsArticle,
+		save.HasVideo,
+		save.ListenDuration,
+	)
 	if err != nil {
-		defer tx.Rollback()
-		return ret, err
+		return err
 	}
-	err = tx.Commit()
+	// the article downloaded from the previous url is stale, and so is how much of it was read
+	result, err := tx.Exec("DELETE FROM article WHERE save_id = ? AND url != ?", save.Id, save.Url)
 	if err != nil {
-		defer tx.Rollback()
-		return ret, err
+		return err
+	}
+	if stale, _ := result.RowsAffected(); stale > 0 {
+		if _, err = tx.Exec("DELETE FROM reading_progress WHERE save_id = ?", save.Id); err != nil {
+			return err
+		}
+	}
+	if err = indexSave(tx, save.Id); err != nil {
+		return err
+	}
+	return setSaveTags(tx, save.Id, save.Tags)
+}
+
+func SavesStatusChange(status uint8, ids ...string) LocalChange {
+	return func(tx *sql.Tx) error {
+		return execForIds(tx, "UPDATE save SET status = ? WHERE id = ?", status, ids)
 	}
-	return ret, nil
+}
+
+func SavesFavoriteChange(favorite bool, ids ...string) LocalChange {
+	return func(tx *sql.Tx) error {
+		return execForIds(tx, "UPDATE save SET favorite = ? WHERE id = ?", favorite, ids)
+	}
+}
+
+func DeleteSaves(ids ...string) error {
+	return applyChange(DeleteSavesChange(ids...))
+}
+
+func DeleteSavesChange(ids ...string) LocalChange {
+	return func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if err := unindexSave(tx, id); err != nil {
+				return err
+			}
+			if _, err := tx.Exec("DELETE FROM save WHERE id = ?", id); err != nil {
+				return err
+			}
+		}
+		return deleteUnusedTags(tx)
+	}
+}
+
+func execForIds(tx *sql.Tx, query string, value any, ids []string) error {
+	for _, id := range ids {
+		if _, err := tx.Exec(query, value, id); err != nil {
+			return err
+		}
+	}
+	return nil
 }
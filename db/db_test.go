@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openTestDB connects DB to a new migrated database for the duration of the test
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = migrate(db); err == nil {
+		err = openSearchIndex(db)
+	}
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		DB = previous
+		db.Close()
+	})
+}
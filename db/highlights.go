@@ -0,0 +1,78 @@
+package db
+
+import (
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+func GetHighlights(saveId string) ([]models.Highlight, error) {
+	highlights := make([]models.Highlight, 0)
+	rows, err := DB.Query(`
+		SELECT id, save_id, text, note, created_on
+		  FROM highlight
+		 WHERE save_id = ?
+		 ORDER BY id`,
+		saveId,
+	)
+	if err != nil {
+		return highlights, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h models.Highlight
+		if err = rows.Scan(&h.Id, &h.SaveId, &h.Text, &h.Note, &h.CreatedOn); err != nil {
+			return highlights, err
+		}
+		highlights = append(highlights, h)
+	}
+	return highlights, rows.Err()
+}
+
+// GetAllHighlights returns the highlights of every save, saves added last first
+func GetAllHighlights() ([]models.SaveHighlights, error) {
+	list := make([]models.SaveHighlights, 0)
+	rows, err := DB.Query(`
+		SELECT ` + saveColumns("s") + `, h.id, h.save_id, h.text, h.note, h.created_on
+		  FROM highlight h
+		  JOIN save s ON s.id = h.save_id
+		 ORDER BY s.added_on DESC, s.id, h.id`,
+	)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h models.Highlight
+		save, err := scanSave(rows, &h.Id, &h.SaveId, &h.Text, &h.Note, &h.CreatedOn)
+		if err != nil {
+			return list, err
+		}
+		if len(list) == 0 || list[len(list)-1].Save.Id != save.Id {
+			list = append(list, models.SaveHighlights{Save: save, Highlights: make([]models.Highlight, 0)})
+		}
+		last := &list[len(list)-1]
+		last.Highlights = append(last.Highlights, h)
+	}
+	return list, rows.Err()
+}
+
+// SaveHighlight adds the highlight, or replaces the note of the one with the same text
+func SaveHighlight(saveId, text, note string) error {
+	_, err := DB.Exec(`
+		INSERT INTO highlight(save_id, text, note, created_on)
+		VALUES (?,?,?,?)
+		ON CONFLICT(save_id, text) DO
+		UPDATE SET note = excluded.note`,
+		saveId,
+		text,
+		note,
+		time.Now().Unix(),
+	)
+	return err
+}
+
+func DeleteHighlight(id int64) error {
+	_, err := DB.Exec("DELETE FROM highlight WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type migration struct {
+	name string
+	up   func(tx *sql.Tx) error
+}
+
+// migrations are applied in order, the schema version stored in
+// PRAGMA user_version is the number of migrations already applied.
+// Never edit or reorder a released migration, append a new one instead.
+var migrations = []migration{
+	{name: "initial schema", up: createInitialSchema},
+	{name: "normalize tags", up: normalizeTags},
+	{name: "pending actions", up: createPendingActions},
+	{name: "save details", up: addSaveDetails},
+	{name: "article cache", up: createArticles},
+	{name: "article failures", up: createArticleFailures},
+	{name: "reading progress", up: createReadingProgress},
+	{name: "highlights", up: createHighlights},
+	{name: "search index", up: createSearchIndex},
+}
+
+type NewerSchemaErr struct {
+	Version int
+	Known   int
+}
+
+func (e NewerSchemaErr) Error() string {
+	return fmt.Sprintf("database schema version %d is newer than the latest known version %d, please update tasca", e.Version, e.Known)
+}
+
+func SchemaVersion(db *sql.DB) (version int, err error) {
+	err = db.QueryRow("PRAGMA user_version").Scan(&version)
+	return
+}
+
+func migrate(db *sql.DB) error {
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return NewerSchemaErr{Version: version, Known: len(migrations)}
+	}
+	for i := version; i < len(migrations); i++ {
+		if err = applyMigration(db, i+1, migrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, version int, m migration) error {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+	if err = m.up(tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d (%s): %w", version, m.name, err)
+	}
+	if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("migration %d (%s): %w", version, m.name, err)
+	}
+	return tx.Commit()
+}
+
+func createInitialSchema(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS user (
+			username         TEXT PRIMARY KEY,
+			access_token     TEXT,
+			saves_updated_on INTEGER(8)
+		)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS save (
+			id           TEXT PRIMARY KEY,
+			title        TEXT,
+			url          TEXT NOT NULL,
+			description  TEXT,
+			status       INTEGER(1),
+			favorite     INTEGER(1),
+			tags         TEXT,
+			time_to_read INTEGER,
+			added_on     INTEGER(8),
+			updated_on   INTEGER(8)
+		)`)
+	return err
+}
+
+func normalizeTags(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE tag (
+			id   INTEGER PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE
+		)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		CREATE TABLE save_tag (
+			save_id TEXT NOT NULL REFERENCES save(id) ON DELETE CASCADE,
+			tag_id  INTEGER NOT NULL REFERENCES tag(id) ON DELETE CASCADE,
+			PRIMARY KEY (save_id, tag_id)
+		)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("CREATE INDEX save_tag_tag_id ON save_tag(tag_id)")
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT id, tags FROM save WHERE tags IS NOT NULL AND tags != ''")
+	if err != nil {
+		return err
+	}
+	saveTags := make(map[string][]string)
+	for rows.Next() {
+		var id, tags string
+		if err = rows.Scan(&id, &tags); err != nil {
+			rows.Close()
+			return err
+		}
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				saveTags[id] = append(saveTags[id], tag)
+			}
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for id, tags := range saveTags {
+		if err = setSaveTags(tx, id, tags); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("ALTER TABLE save DROP COLUMN tags")
+	return err
+}
+
+func createPendingActions(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE pending_action (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			save_id    TEXT NOT NULL,
+			action     TEXT NOT NULL,
+			payload    TEXT NOT NULL,
+			attempts   INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			failed     INTEGER NOT NULL DEFAULT 0,
+			created_on INTEGER NOT NULL,
+			retry_on   INTEGER NOT NULL DEFAULT 0
+		)`)
+	return err
+}
+
+func addSaveDetails(tx *sql.Tx) error {
+	columns := []string{
+		"resolved_url TEXT NOT NULL DEFAULT ''",
+		"word_count INTEGER NOT NULL DEFAULT 0",
+		"lang TEXT NOT NULL DEFAULT ''",
+		"top_image_url TEXT NOT NULL DEFAULT ''",
+		"authors TEXT NOT NULL DEFAULT '[]'",
+		"images TEXT NOT NULL DEFAULT '[]'",
+		"domain TEXT NOT NULL DEFAULT ''",
+		"is_article INTEGER NOT NULL DEFAULT 0",
+		"has_video INTEGER NOT NULL DEFAULT 0",
+		"listen_duration INTEGER NOT NULL DEFAULT 0",
+	}
+	for _, column := range columns {
+		if _, err := tx.Exec("ALTER TABLE save ADD COLUMN " + column); err != nil {
+			return err
+		}
+	}
+	// incremental syncs only return the saves changed since the last one,
+	// a full sync fills the details of the saves already stored
+	_, err := tx.Exec("UPDATE user SET saves_updated_on = 0")
+	return err
+}
+
+func createArticles(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE article (
+			save_id    TEXT PRIMARY KEY REFERENCES save(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			url        TEXT NOT NULL,
+			text       TEXT NOT NULL,
+			html       TEXT NOT NULL,
+			byline     TEXT NOT NULL DEFAULT '',
+			site_name  TEXT NOT NULL DEFAULT '',
+			fetched_on INTEGER NOT NULL
+		)`)
+	return err
+}
+
+func createArticleFailures(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE article_failure (
+			save_id   TEXT PRIMARY KEY REFERENCES save(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			url       TEXT NOT NULL,
+			error     TEXT NOT NULL,
+			attempts  INTEGER NOT NULL DEFAULT 1,
+			failed_on INTEGER NOT NULL
+		)`)
+	return err
+}
+
+func createReadingProgress(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE reading_progress (
+			save_id    TEXT PRIMARY KEY REFERENCES save(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			percent    INTEGER NOT NULL,
+			updated_on INTEGER NOT NULL
+		)`)
+	return err
+}
+
+func createHighlights(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE highlight (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			save_id    TEXT NOT NULL REFERENCES save(id) ON DELETE CASCADE ON UPDATE CASCADE,
+			text       TEXT NOT NULL,
+			note       TEXT NOT NULL DEFAULT '',
+			created_on INTEGER NOT NULL,
+			UNIQUE(save_id, text)
+		)`)
+	return err
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// openBaselineDB returns a database with the schema created before the migrations
+func openBaselineDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "cache.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	statements := []string{
+		`CREATE TABLE user (
+			username         TEXT PRIMARY KEY,
+			access_token     TEXT,
+			saves_updated_on INTEGER(8)
+		)`,
+		`CREATE TABLE save (
+			id           TEXT PRIMARY KEY,
+			title        TEXT,
+			url          TEXT NOT NULL,
+			description  TEXT,
+			status       INTEGER(1),
+			favorite     INTEGER(1),
+			tags         TEXT,
+			time_to_read INTEGER,
+			added_on     INTEGER(8),
+			updated_on   INTEGER(8)
+		)`,
+		"INSERT INTO user VALUES ('user', 'token', 1700000000)",
+		`INSERT INTO save VALUES
+			('1', 'One', 'https://a.com', '', 0, 0, 'a, b', 1, 1690000000, 1690000000),
+			('2', 'Two', 'https://b.com', '', 1, 1, '', 2, 1690000001, 1690000001)`,
+	}
+	for _, statement := range statements {
+		if _, err = db.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestMigrateBaseline(t *testing.T) {
+	db := openBaselineDB(t)
+	if err := migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("version = %d, want %d", version, len(migrations))
+	}
+
+	rows, err := db.Query(`
+		SELECT st.save_id, t.name FROM save_tag st
+		JOIN tag t ON t.id = st.tag_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := make([]string, 0)
+	for rows.Next() {
+		var id, name string
+		if err = rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		tags = append(tags, id+":"+name)
+	}
+	rows.Close()
+	sort.Strings(tags)
+	if len(tags) != 2 || tags[0] != "1:a" || tags[1] != "1:b" {
+		t.Errorf("tags = %v, want [1:a 1:b]", tags)
+	}
+
+	var cursor int64
+	if err = db.QueryRow("SELECT saves_updated_on FROM user").Scan(&cursor); err != nil {
+		t.Fatal(err)
+	}
+	if cursor != 0 {
+		t.Errorf("saves_updated_on = %d, want 0 to run a full sync", cursor)
+	}
+
+	var domain, authors string
+	var wordCount int
+	err = db.QueryRow("SELECT domain, authors, word_count FROM save WHERE id = '1'").Scan(&domain, &authors, &wordCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain != "" || authors != "[]" || wordCount != 0 {
+		t.Errorf("got domain %q authors %q word count %d, want the defaults", domain, authors, wordCount)
+	}
+
+	if err = migrate(db); err != nil {
+		t.Errorf("migrating again: %v", err)
+	}
+}
+
+func TestMigrateNewerSchema(t *testing.T) {
+	db := openBaselineDB(t)
+	if _, err := db.Exec("PRAGMA user_version = 1000"); err != nil {
+		t.Fatal(err)
+	}
+	var newer NewerSchemaErr
+	if err := migrate(db); !errors.As(err, &newer) {
+		t.Errorf("got %v, want a NewerSchemaErr", err)
+	}
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// PendingAction is an action applied to the local saves that still has to be sent to Pocket.
+// Payload is the encoded action, its item id is always taken from SaveId since
+// saves added offline only get their id once the add action has been sent.
+type PendingAction struct {
+	Id        int64
+	SaveId    string
+	Action    string
+	Payload   string
+	Attempts  int
+	LastError string
+	Failed    bool
+	CreatedOn int64
+	RetryOn   int64
+}
+
+// LocalChange is the change made to the local saves by an action
+type LocalChange func(tx *sql.Tx) error
+
+// QueuedAction is an action to send to Pocket, see PendingAction
+type QueuedAction struct {
+	SaveId  string
+	Action  string
+	Payload string
+}
+
+// QueueActions queues the actions and applies their local change in a single transaction,
+// so that an action is never sent to Pocket without its change being applied, or the other way round
+func QueueActions(change LocalChange, actions ...QueuedAction) error {
+	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	for _, action := range actions {
+		_, err = tx.Exec(
+			"INSERT INTO pending_action(save_id, action, payload, created_on) VALUES (?,?,?,?)",
+			action.SaveId,
+			action.Action,
+			action.Payload,
+			now,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = change(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetQueuedActions returns the actions to send, in the order they were queued
+func GetQueuedActions() ([]PendingAction, error) {
+	return queryPendingActions("WHERE failed = 0")
+}
+
+func GetFailedActions() ([]PendingAction, error) {
+	return queryPendingActions("WHERE failed = 1")
+}
+
+func CountPendingActions() (pending int, failed int, err error) {
+	err = DB.QueryRow(`
+		SELECT COALESCE(SUM(failed = 0), 0), COALESCE(SUM(failed = 1), 0)
+		  FROM pending_action`,
+	).Scan(&pending, &failed)
+	return
+}
+
+func CompletePendingActions(ids ...int64) error {
+	return execForActions("DELETE FROM pending_action WHERE id = ?", ids)
+}
+
+func FailPendingAction(id int64, reason string) error {
+	_, err := DB.Exec(
+		"UPDATE pending_action SET failed = 1, attempts = attempts + 1, last_error = ? WHERE id = ?",
+		reason,
+		id,
+	)
+	return err
+}
+
+// PostponePendingActions records a failed attempt, the actions are sent again after retryOn
+func PostponePendingActions(retryOn int64, reason string, ids ...int64) error {
+	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		_, err = tx.Exec(
+			"UPDATE pending_action SET attempts = attempts + 1, last_error = ?, retry_on = ? WHERE id = ?",
+			reason,
+			retryOn,
+			id,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func RetryFailedActions() error {
+	_, err := DB.Exec("UPDATE pending_action SET failed = 0, attempts = 0, retry_on = 0 WHERE failed = 1")
+	return err
+}
+
+func ClearFailedActions() error {
+	_, err := DB.Exec("DELETE FROM pending_action WHERE failed = 1")
+	return err
+}
+
+// ReplaceLocalSave gives the id assigned by Pocket to a save added offline,
+// keeping the changes made to it in the meantime
+func ReplaceLocalSave(localId string, save models.PocketSave) error {
+	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM save WHERE id = ?)", save.Id).Scan(&exists)
+	if err == nil {
+		_, err = tx.Exec("UPDATE pending_action SET save_id = ? WHERE save_id = ?", save.Id, localId)
+	}
+	if err == nil && exists {
+		// the url was already saved, Pocket returned the existing save
+		if err = unindexSave(tx, localId); err == nil {
+			_, err = tx.Exec("DELETE FROM save WHERE id = ?", localId)
+		}
+	} else if err == nil {
+		// save_tag references the old id until the end of the transaction
+		if _, err = tx.Exec("PRAGMA defer_foreign_keys = ON"); err == nil {
+			_, err = tx.Exec("UPDATE save SET id = ? WHERE id = ?", save.Id, localId)
+		}
+		if err == nil {
+			_, err = tx.Exec("UPDATE save_tag SET save_id = ? WHERE save_id = ?", save.Id, localId)
+		}
+		if err == nil {
+			err = reindexSave(tx, localId, save.Id)
+		}
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if save.SaveTitle != "" {
+		_, err = tx.Exec("UPDATE save SET title = ?, description = ? WHERE id = ?", save.SaveTitle, save.SaveDescription, save.Id)
+		if err == nil {
+			err = indexSave(tx, save.Id)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err = deleteUnusedTags(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func queryPendingActions(where string, args ...any) ([]PendingAction, error) {
+	actions := make([]PendingAction, 0)
+	rows, err := DB.Query(`
+		SELECT id, save_id, action, payload, attempts, last_error, failed, created_on, retry_on
+		  FROM pending_action
+		 `+where+`
+		 ORDER BY id`,
+		args...,
+	)
+	if err != nil {
+		return actions, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var a PendingAction
+		err = rows.Scan(&a.Id, &a.SaveId, &a.Action, &a.Payload, &a.Attempts, &a.LastError, &a.Failed, &a.CreatedOn, &a.RetryOn)
+		if err != nil {
+			return actions, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
+
+func execForActions(query string, ids []int64) error {
+	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err = tx.Exec(query, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func applyChange(change LocalChange) error {
+	tx, err := DB.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: false})
+	if err != nil {
+		return err
+	}
+	if err = change(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// GetReadProgress returns how much of the article of the save has been read, in percent
+func GetReadProgress(saveId string) (int, error) {
+	var percent int
+	err := DB.QueryRow("SELECT percent FROM reading_progress WHERE save_id = ?", saveId).Scan(&percent)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return percent, err
+}
+
+func SetReadProgress(saveId string, percent int) error {
+	_, err := DB.Exec(`
+		INSERT INTO reading_progress(save_id, percent, updated_on)
+		VALUES (?,?,?)
+		ON CONFLICT(save_id) DO
+		UPDATE SET
+			   percent = excluded.percent,
+			updated_on = excluded.updated_on`,
+		saveId,
+		percent,
+		time.Now().Unix(),
+	)
+	return err
+}
+
+func attachReadProgress(saves []models.PocketSave) error {
+	if len(saves) == 0 {
+		return nil
+	}
+	rows, err := DB.Query("SELECT save_id, percent FROM reading_progress")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	progress := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var percent int
+		if err = rows.Scan(&id, &percent); err != nil {
+			return err
+		}
+		progress[id] = percent
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i := range saves {
+		saves[i].ReadProgress = progress[saves[i].Id]
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+package db
+
+import (
+	"database/sql"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// Markers wrapping the matched text in SearchResult.Title and SearchResult.Snippet
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// searchEnabled is false when sqlite has been built without FTS5
+// (see the sqlite_fts5 build tag), search then falls back to LIKE queries
+var searchEnabled bool
+
+type SearchQuery struct {
+	Terms    []string
+	Phrases  []string
+	Tags     []string
+	Domains  []string
+	Favorite bool
+	Status   []uint8
+}
+
+type SearchResult struct {
+	Save    models.PocketSave
+	Title   string
+	Snippet string
+}
+
+func (q SearchQuery) hasText() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0
+}
+
+// ParseSearchQuery parses queries like `tag:go domain:nytimes.com is:fav "exact phrase" word`
+func ParseSearchQuery(query string) SearchQuery {
+	q := SearchQuery{}
+	for _, token := range tokenize(query) {
+		if token.quoted {
+			q.Phrases = append(q.Phrases, token.value)
+			continue
+		}
+		key, value, found := strings.Cut(token.value, ":")
+		if !found || value == "" {
+			q.Terms = append(q.Terms, token.value)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "tag":
+			q.Tags = append(q.Tags, value)
+		case "domain", "site":
+			q.Domains = append(q.Domains, strings.ToLower(value))
+		case "is":
+			switch strings.ToLower(value) {
+			case "fav", "favorite":
+				q.Favorite = true
+			case "unread":
+				q.Status = append(q.Status, models.StatusOK)
+			case "archived", "archive":
+				q.Status = append(q.Status, models.StatusArchived)
+			default:
+				q.Terms = append(q.Terms, token.value)
+			}
+		default:
+			q.Terms = append(q.Terms, token.value)
+		}
+	}
+	return q
+}
+
+type token struct {
+	value  string
+	quoted bool
+}
+
+func tokenize(query string) []token {
+	tokens := make([]token, 0)
+	var current strings.Builder
+	quoted, inQuotes := false, false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, token{value: current.String(), quoted: quoted})
+		}
+		current.Reset()
+		quoted = false
+	}
+	for _, r := range query {
+		switch {
+		case r == '"':
+			// a quote opening a token starts a phrase, otherwise it only
+			// groups words, e.g. `tag:"two words"`
+			if !inQuotes && current.Len() == 0 {
+				quoted = true
+			}
+			inQuotes = !inQuotes
+			if !inQuotes && quoted {
+				flush()
+			}
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// FullTextSearch tells whether search uses the full-text index, results
+// are not ranked nor is the downloaded content searched otherwise
+func FullTextSearch() bool {
+	return searchEnabled
+}
+
+func SearchSaves(query string) ([]SearchResult, error) {
+	q := ParseSearchQuery(query)
+	if searchEnabled && q.hasText() {
+		return searchFTS(q)
+	}
+	return searchLike(q)
+}
+
+func searchFTS(q SearchQuery) ([]SearchResult, error) {
+	terms := make([]string, 0, len(q.Terms)+len(q.Phrases))
+	for _, term := range q.Terms {
+		terms = append(terms, quoteFTS(term)+"*")
+	}
+	for _, phrase := range q.Phrases {
+		terms = append(terms, quoteFTS(phrase))
+	}
+	where, args := filters(q)
+	args = append([]any{HighlightStart, HighlightEnd, HighlightStart, HighlightEnd, strings.Join(terms, " ")}, args...)
+	rows, err := DB.Query(`
+		SELECT `+saveColumns("s")+`,
+		       highlight(save_search, 1, ?, ?),
+		       snippet(save_search, -1, ?, ?, '…', 24)
+		  FROM save_search
+		  JOIN save s ON s.id = save_search.save_id
+		 WHERE save_search MATCH ?`+where+`
+		 ORDER BY bm25(save_search, 0.0, 10.0, 4.0, 2.0, 1.0)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchResults(rows, q, true)
+}
+
+func searchLike(q SearchQuery) ([]SearchResult, error) {
+	where, args := filters(q)
+	texts := append(slices.Clone(q.Terms), q.Phrases...)
+	for _, text := range texts {
+		where += " AND (s.title LIKE ? OR s.description LIKE ? OR s.url LIKE ?)"
+		pattern := "%" + text + "%"
+		args = append(args, pattern, pattern, pattern)
+	}
+	rows, err := DB.Query(`
+		SELECT `+saveColumns("s")+`
+		  FROM save s
+		 WHERE 1 = 1`+where+`
+		 ORDER BY s.added_on DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSearchResults(rows, q, false)
+}
+
+func filters(q SearchQuery) (string, []any) {
+	where := ""
+	args := make([]any, 0)
+	for _, tag := range q.Tags {
+		where += " AND s.id IN (SELECT st.save_id FROM save_tag st JOIN tag t ON t.id = st.tag_id WHERE t.name = ?)"
+		args = append(args, tag)
+	}
+	for _, domain := range q.Domains {
+		// narrows the saves to compare the host of, see matchesDomains
+		where += " AND (lower(s.url) LIKE ? OR lower(s.resolved_url) LIKE ?)"
+		args = append(args, "%"+domain+"%", "%"+domain+"%")
+	}
+	if q.Favorite {
+		where += " AND s.favorite = 1"
+	}
+	if len(q.Status) > 0 {
+		where += " AND s.status IN (?" + strings.Repeat(",?", len(q.Status)-1) + ")"
+		for _, status := range q.Status {
+			args = append(args, status)
+		}
+	}
+	return where, args
+}
+
+func scanSearchResults(rows *sql.Rows, q SearchQuery, highlighted bool) ([]SearchResult, error) {
+	defer rows.Close()
+	terms := append(slices.Clone(q.Terms), q.Phrases...)
+	results := make([]SearchResult, 0)
+	saves := make([]models.PocketSave, 0)
+	for rows.Next() {
+		var (
+			save models.PocketSave
+			err  error
+		)
+		result := SearchResult{}
+		if highlighted {
+			save, err = scanSave(rows, &result.Title, &result.Snippet)
+		} else {
+			save, err = scanSave(rows)
+			result.Title = highlightTerms(save.SaveTitle, terms)
+			result.Snippet = highlightTerms(save.Description(), terms)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !matchesDomains(save, q.Domains) {
+			continue
+		}
+		save.Tags = []string{}
+		saves = append(saves, save)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := attachTags(saves); err != nil {
+		return nil, err
+	}
+	if err := attachReadProgress(saves); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Save = saves[i]
+	}
+	return results, nil
+}
+
+// matchesDomains tells whether the save belongs to all the domains or to their subdomains
+func matchesDomains(save models.PocketSave, domains []string) bool {
+	hosts := make([]string, 0, 2)
+	for _, rawUrl := range []string{save.Url, save.ResolvedUrl} {
+		if parsed, err := url.Parse(rawUrl); err == nil && parsed.Hostname() != "" {
+			hosts = append(hosts, strings.ToLower(parsed.Hostname()))
+		}
+	}
+	for _, domain := range domains {
+		if !slices.ContainsFunc(hosts, func(host string) bool {
+			return host == domain || strings.HasSuffix(host, "."+domain)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+func highlightTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if term == "" || len(lower) != len(text) {
+			continue
+		}
+		for start := 0; ; {
+			i := strings.Index(lower[start:], term)
+			if i < 0 {
+				break
+			}
+			for j := start + i; j < start+i+len(term); j++ {
+				marked[j] = true
+			}
+			start += i + len(term)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(HighlightStart)
+		}
+		b.WriteByte(text[i])
+		if marked[i] && (i == len(text)-1 || !marked[i+1]) {
+			b.WriteString(HighlightEnd)
+		}
+	}
+	return b.String()
+}
+
+func quoteFTS(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
+
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// fts5Available tells whether sqlite has been built with FTS5
+func fts5Available(q queryer) (available bool, err error) {
+	err = q.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&available)
+	return
+}
+
+// createSearchIndex creates the full-text index of the saves and of their downloaded
+// articles, builds without FTS5 skip it and search with LIKE queries instead.
+// The index is keyed on the id of the saves: the rowid of save is not stable.
+func createSearchIndex(tx *sql.Tx) error {
+	if available, err := fts5Available(tx); err != nil || !available {
+		return err
+	}
+	// the index was created outside of the migrations by earlier versions
+	_, err := tx.Exec("DROP TABLE IF EXISTS save_search")
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		CREATE VIRTUAL TABLE save_search USING fts5(
+			save_id UNINDEXED,
+			title,
+			description,
+			url,
+			content,
+			tokenize = 'unicode61 remove_diacritics 2'
+		)`)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO save_search(save_id, title, description, url, content)
+		SELECT s.id, s.title, s.description, s.url, coalesce(a.text, '')
+		  FROM save s
+		  LEFT JOIN article a ON a.save_id = s.id`)
+	return err
+}
+
+// openSearchIndex enables the search index, it is created when the database
+// has been migrated by a build without FTS5 or by an earlier version
+func openSearchIndex(db *sql.DB) error {
+	available, err := fts5Available(db)
+	if err != nil || !available {
+		searchEnabled = false
+		return err
+	}
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM pragma_table_info('save_search') WHERE name = 'save_id')").Scan(&exists)
+	if err == nil && !exists {
+		var tx *sql.Tx
+		if tx, err = db.Begin(); err != nil {
+			return err
+		}
+		if err = createSearchIndex(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Commit()
+	}
+	searchEnabled = err == nil
+	return err
+}
+
+func indexSave(tx *sql.Tx, id string) error {
+	if !searchEnabled {
+		return nil
+	}
+	var content string
+	err := tx.QueryRow("SELECT content FROM save_search WHERE save_id = ?", id).Scan(&content)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err = unindexSave(tx, id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO save_search(save_id, title, description, url, content)
+		SELECT id, title, description, url, ?
+		  FROM save
+		 WHERE id = ?`,
+		content,
+		id,
+	)
+	return err
+}
+
+func unindexSave(tx *sql.Tx, id string) error {
+	if !searchEnabled {
+		return nil
+	}
+	_, err := tx.Exec("DELETE FROM save_search WHERE save_id = ?", id)
+	return err
+}
+
+// reindexSave moves the index of the save to its new id
+func reindexSave(tx *sql.Tx, oldId, newId string) error {
+	if !searchEnabled {
+		return nil
+	}
+	_, err := tx.Exec("UPDATE save_search SET save_id = ? WHERE save_id = ?", newId, oldId)
+	return err
+}
+
+func IndexArticleContent(id string, content string) error {
+	if !searchEnabled {
+		return nil
+	}
+	_, err := DB.Exec(
+		"UPDATE save_search SET content = ? WHERE save_id = ?",
+		content,
+		id,
+	)
+	return err
+}
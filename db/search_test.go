@@ -0,0 +1,103 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	q := ParseSearchQuery(`tag:go domain:NYTimes.com is:fav "exact phrase" word is:archived tag:"two words" foo:bar is:other`)
+	want := SearchQuery{
+		Terms:    []string{"word", "foo:bar", "is:other"},
+		Phrases:  []string{"exact phrase"},
+		Tags:     []string{"go", "two words"},
+		Domains:  []string{"nytimes.com"},
+		Favorite: true,
+		Status:   []uint8{models.StatusArchived},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("got %+v, want %+v", q, want)
+	}
+}
+
+func TestSearchSaves(t *testing.T) {
+	openTestDB(t)
+	err := UpsertSaves(
+		models.PocketSave{Id: "1", SaveTitle: "Error handling in Go", Url: "https://go.dev/blog/errors", Tags: []string{"go"}, AddedOn: 1},
+		models.PocketSave{Id: "2", SaveTitle: "Rust errors", Url: "https://www.nytimes.com/rust", Favorite: true, AddedOn: 2},
+		models.PocketSave{Id: "3", SaveTitle: "Go generics", Url: "https://nytimes.com.example.org/go", Status: models.StatusArchived, AddedOn: 3},
+		models.PocketSave{Id: "4", SaveTitle: "Unrelated", Url: "https://example.org/?from=.nytimes.com", AddedOn: 4},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		ids   []string
+	}{
+		{query: "error", ids: []string{"1", "2"}},
+		{query: `"error handling"`, ids: []string{"1"}},
+		{query: "error tag:go", ids: []string{"1"}},
+		{query: "is:fav", ids: []string{"2"}},
+		{query: "go is:archived", ids: []string{"3"}},
+		{query: "domain:nytimes.com", ids: []string{"2"}},
+		{query: "domain:example.org", ids: []string{"3", "4"}},
+		{query: "missing", ids: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := SearchSaves(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(results))
+			for _, result := range results {
+				ids = append(ids, result.Save.Id)
+			}
+			if !sameIds(ids, tt.ids) {
+				t.Errorf("got %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+
+	results, err := SearchSaves("rust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Title, HighlightStart+"Rust"+HighlightEnd) {
+		t.Errorf("got %+v, want the title with the match highlighted", results)
+	}
+}
+
+func TestSearchSavesArticleContent(t *testing.T) {
+	openTestDB(t)
+	if !FullTextSearch() {
+		t.Skip("built without the sqlite_fts5 tag")
+	}
+	err := UpsertSaves(models.PocketSave{Id: "1", SaveTitle: "Title", Url: "https://example.org"})
+	if err == nil {
+		err = IndexArticleContent("1", "the content mentions goroutines")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := SearchSaves("goroutine")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !strings.Contains(results[0].Snippet, HighlightStart+"goroutines"+HighlightEnd) {
+		t.Errorf("got %+v, want the save with the content highlighted", results)
+	}
+}
+
+// sameIds compares the ids regardless of their order, which depends on the ranking
+func sameIds(got, want []string) bool {
+	got, want = slices.Clone(got), slices.Clone(want)
+	slices.Sort(got)
+	slices.Sort(want)
+	return slices.Equal(got, want)
+}
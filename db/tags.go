@@ -0,0 +1,226 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+func GetAllTags() ([]string, error) {
+	tags := make([]string, 0)
+	rows, err := DB.Query("SELECT name FROM tag ORDER BY name")
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return tags, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+func GetTagCounts() ([]models.PocketTag, error) {
+	tags := make([]models.PocketTag, 0)
+	rows, err := DB.Query(`
+		SELECT t.name, COUNT(st.save_id)
+		  FROM tag t
+		  JOIN save_tag st ON st.tag_id = t.id
+		 GROUP BY t.id
+		 ORDER BY t.name`,
+	)
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag models.PocketTag
+		if err = rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return tags, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func GetPocketSavesByTag(tag string, filter SavesFilter) (list []models.PocketSave, err error) {
+	list = make([]models.PocketSave, 0)
+	where, ok := savesFilterWhere[filter]
+	if !ok {
+		err = fmt.Errorf("saves: unknown filter %d", filter)
+		return
+	}
+	rows, err := DB.Query(`
+		SELECT `+saveColumns("")+`
+		  FROM save
+		 WHERE `+where+`
+		   AND id IN (SELECT st.save_id FROM save_tag st JOIN tag t ON t.id = st.tag_id WHERE t.name = ?)
+		 ORDER BY added_on DESC`,
+		tag,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var save models.PocketSave
+		if save, err = scanSave(rows); err != nil {
+			return
+		}
+		list = append(list, save)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	if err = attachTags(list); err != nil {
+		return
+	}
+	err = attachReadProgress(list)
+	return
+}
+
+func SavesTagsChange(tags []string, ids ...string) LocalChange {
+	return func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if err := setSaveTags(tx, id, tags); err != nil {
+				return err
+			}
+		}
+		return deleteUnusedTags(tx)
+	}
+}
+
+func RenameTag(oldTag, newTag string) error {
+	return applyChange(RenameTagChange(oldTag, newTag))
+}
+
+// RenameTagChange renames the tag, merging it into newTag if it already exists
+func RenameTagChange(oldTag, newTag string) LocalChange {
+	return func(tx *sql.Tx) error {
+		if oldTag == newTag {
+			// merging the tag into itself would delete it
+			return nil
+		}
+		var newId int64
+		err := tx.QueryRow("SELECT id FROM tag WHERE name = ?", newTag).Scan(&newId)
+		if err == sql.ErrNoRows {
+			_, err = tx.Exec("UPDATE tag SET name = ? WHERE name = ?", newTag, oldTag)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		// the new tag already exists, merge the old one into it
+		_, err = tx.Exec(`
+			INSERT OR IGNORE INTO save_tag(save_id, tag_id)
+			SELECT st.save_id, ?
+			  FROM save_tag st
+			  JOIN tag t ON t.id = st.tag_id
+			 WHERE t.name = ?`,
+			newId,
+			oldTag,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("DELETE FROM tag WHERE name = ?", oldTag)
+		return err
+	}
+}
+
+func DeleteTag(tag string) error {
+	return applyChange(DeleteTagChange(tag))
+}
+
+func DeleteTagChange(tag string) LocalChange {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM tag WHERE name = ?", tag)
+		return err
+	}
+}
+
+func getSaveTags(id string) ([]string, error) {
+	tags := make([]string, 0)
+	rows, err := DB.Query(`
+		SELECT t.name
+		  FROM save_tag st
+		  JOIN tag t ON t.id = st.tag_id
+		 WHERE st.save_id = ?
+		 ORDER BY t.name`,
+		id,
+	)
+	if err != nil {
+		return tags, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return tags, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+func attachTags(saves []models.PocketSave) error {
+	if len(saves) == 0 {
+		return nil
+	}
+	rows, err := DB.Query(`
+		SELECT st.save_id, t.name
+		  FROM save_tag st
+		  JOIN tag t ON t.id = st.tag_id
+		 ORDER BY t.name`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var id, name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		tags[id] = append(tags[id], name)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for i := range saves {
+		if t, ok := tags[saves[i].Id]; ok {
+			saves[i].Tags = t
+		}
+	}
+	return nil
+}
+
+func setSaveTags(tx *sql.Tx, id string, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM save_tag WHERE save_id = ?", id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tag(name) VALUES (?)", tag); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO save_tag(save_id, tag_id) SELECT ?, id FROM tag WHERE name = ?",
+			id,
+			tag,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteUnusedTags(tx *sql.Tx) error {
+	_, err := tx.Exec("DELETE FROM tag WHERE id NOT IN (SELECT tag_id FROM save_tag)")
+	return err
+}
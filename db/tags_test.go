@@ -0,0 +1,72 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+func TestRenameTag(t *testing.T) {
+	tests := []struct {
+		name   string
+		oldTag string
+		newTag string
+		want   map[string][]string
+		tags   int
+	}{
+		{name: "new name", oldTag: "go", newTag: "golang", want: map[string][]string{"1": {"golang", "lang"}, "2": {"golang"}, "3": {"rust"}}, tags: 3},
+		{name: "merge", oldTag: "go", newTag: "lang", want: map[string][]string{"1": {"lang"}, "2": {"lang"}, "3": {"rust"}}, tags: 2},
+		{name: "same name", oldTag: "go", newTag: "go", want: map[string][]string{"1": {"go", "lang"}, "2": {"go"}, "3": {"rust"}}, tags: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openTestDB(t)
+			err := UpsertSaves(
+				models.PocketSave{Id: "1", Url: "https://a.com", Tags: []string{"go", "lang"}},
+				models.PocketSave{Id: "2", Url: "https://b.com", Tags: []string{"go"}},
+				models.PocketSave{Id: "3", Url: "https://c.com", Tags: []string{"rust"}},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = RenameTag(tt.oldTag, tt.newTag); err != nil {
+				t.Fatal(err)
+			}
+			for id, want := range tt.want {
+				tags, err := getSaveTags(id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(tags, want) {
+					t.Errorf("save %s has tags %v, want %v", id, tags, want)
+				}
+			}
+			all, err := GetAllTags()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != tt.tags {
+				t.Errorf("got tags %v, want %d", all, tt.tags)
+			}
+		})
+	}
+}
+
+func TestDeleteTag(t *testing.T) {
+	openTestDB(t)
+	err := UpsertSaves(models.PocketSave{Id: "1", Url: "https://a.com", Tags: []string{"go", "lang"}})
+	if err == nil {
+		err = DeleteTag("go")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := getSaveTags("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, []string{"lang"}) {
+		t.Errorf("got tags %v, want [lang]", tags)
+	}
+}
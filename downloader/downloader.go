@@ -0,0 +1,190 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+const (
+	DefaultWorkers = 4
+	// DefaultSiteInterval is the minimum time between two downloads from the same site
+	DefaultSiteInterval = time.Second
+)
+
+var downloading sync.Mutex
+
+type Options struct {
+	Workers      int
+	SiteInterval time.Duration
+	// RetryFailed downloads again the articles that failed on a previous download
+	RetryFailed bool
+}
+
+type Failure struct {
+	Save models.PocketSave
+	Err  error
+}
+
+// Result holds what a download did, saves already downloaded are not part of it
+type Result struct {
+	Downloaded int
+	// Skipped are the saves that failed on a previous download and were not retried
+	Skipped int
+	Failed  []Failure
+}
+
+func (r Result) String() string {
+	counts := []string{fmt.Sprintf("%d downloaded", r.Downloaded)}
+	if len(r.Failed) > 0 {
+		counts = append(counts, fmt.Sprintf("%d failed", len(r.Failed)))
+	}
+	if r.Skipped > 0 {
+		counts = append(counts, fmt.Sprintf("%d skipped", r.Skipped))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// Progress is called after each article is downloaded or fails with the number
+// of articles processed so far and the number of articles to download
+type Progress func(done, total int)
+
+type outcome struct {
+	save    models.PocketSave
+	article models.Article
+	err     error
+}
+
+// Download fetches the articles of the saves that were not downloaded yet and stores them.
+// Failures are stored too, so that an interrupted or failed download can be resumed by
+// calling Download again: only the missing articles are fetched.
+func Download(ctx context.Context, client *lib.Client, saves []models.PocketSave, options Options, progress Progress) (Result, error) {
+	downloading.Lock()
+	defer downloading.Unlock()
+
+	result := Result{Failed: make([]Failure, 0)}
+	missing, failures, err := db.GetArticlesToDownload(saves)
+	if err != nil {
+		return result, err
+	}
+	pending := make([]models.PocketSave, 0, len(missing))
+	for _, save := range missing {
+		if _, failed := failures[save.Id]; failed && !options.RetryFailed {
+			result.Skipped++
+			continue
+		}
+		pending = append(pending, save)
+	}
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	interval := options.SiteInterval
+	if interval <= 0 {
+		interval = DefaultSiteInterval
+	}
+	limiter := siteLimiter{interval: interval, next: make(map[string]time.Time)}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobs := make(chan models.PocketSave)
+	outcomes := make(chan outcome)
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(pending)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for save := range jobs {
+				o := outcome{save: save}
+				if o.err = limiter.wait(ctx, save.Url); o.err == nil {
+					o.article, o.err = client.GetArticle(ctx, save.Id, save.Url)
+				}
+				outcomes <- o
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, save := range pending {
+			select {
+			case jobs <- save:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	// outcomes are stored here rather than by the workers so that writes are not concurrent
+	done := 0
+	for o := range outcomes {
+		if ctx.Err() != nil {
+			// the download was canceled, the article is fetched again on the next one
+			continue
+		}
+		if o.err != nil {
+			result.Failed = append(result.Failed, Failure{Save: o.save, Err: o.err})
+			err = db.FailArticle(o.save.Id, o.save.Url, o.err)
+		} else {
+			result.Downloaded++
+			err = db.SaveArticle(o.article)
+		}
+		if err != nil {
+			cancel()
+			continue
+		}
+		done++
+		if progress != nil {
+			progress(done, len(pending))
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	return result, ctx.Err()
+}
+
+// siteLimiter spaces the requests sent to the same host
+type siteLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func (l *siteLimiter) wait(ctx context.Context, rawUrl string) error {
+	host := rawUrl
+	if u, err := url.Parse(rawUrl); err == nil && u.Host != "" {
+		host = strings.TrimPrefix(u.Hostname(), "www.")
+	}
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,160 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/config"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+const page = `<html><head><title>%s</title></head><body><article>
+<h1>%s</h1>
+<p>The article has enough text for readability to consider it the main content of the page,
+so that it is extracted instead of being discarded as a navigation element or a footer.</p>
+<p>A second paragraph makes the content of the article longer and more believable.</p>
+</article></body></html>`
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	config.InitConfig(config.Config{DBPath: filepath.Join(t.TempDir(), "cache.db")})
+	if err := db.ConnectDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+}
+
+func TestDownloadResume(t *testing.T) {
+	openTestDB(t)
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	broken := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests[r.URL.Path]++
+		if r.URL.Path == "/broken" && broken {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, page, r.URL.Path, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	client := lib.NewClient("consumer-key")
+	client.Retry = lib.RetryPolicy{Attempts: 1}
+
+	saves := []models.PocketSave{
+		{Id: "1", Url: server.URL + "/downloaded"},
+		{Id: "2", Url: server.URL + "/missing"},
+		{Id: "3", Url: server.URL + "/broken"},
+	}
+	if err := db.UpsertSaves(saves...); err != nil {
+		t.Fatal(err)
+	}
+	err := db.SaveArticle(models.Article{SaveId: "1", Url: saves[0].Url, Text: "text", Html: "<p>text</p>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := Options{Workers: 2, SiteInterval: time.Millisecond}
+
+	progress := make([]int, 0)
+	result, err := Download(context.Background(), client, saves, options, func(done, total int) {
+		progress = append(progress, done)
+		if total != 2 {
+			t.Errorf("total = %d, want the 2 articles not downloaded", total)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Downloaded != 1 || len(result.Failed) != 1 || result.Failed[0].Save.Id != "3" {
+		t.Errorf("got %+v, want 1 downloaded and the broken one failed", result)
+	}
+	if len(progress) != 2 || progress[1] != 2 {
+		t.Errorf("got progress %v, want [1 2]", progress)
+	}
+	if requests["/downloaded"] != 0 {
+		t.Error("the article already downloaded is fetched again")
+	}
+	article, err := db.GetArticle("2", saves[1].Url)
+	if err != nil || !strings.Contains(article.Text, "main content") {
+		t.Errorf("got article %+v, err %v", article, err)
+	}
+
+	// the failed article is skipped unless retried
+	result, err = Download(context.Background(), client, saves, options, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Downloaded != 0 || result.Skipped != 1 || requests["/broken"] != 1 {
+		t.Errorf("got %+v and %d requests, want the broken article skipped", result, requests["/broken"])
+	}
+
+	mu.Lock()
+	broken = false
+	mu.Unlock()
+	options.RetryFailed = true
+	result, err = Download(context.Background(), client, saves, options, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Downloaded != 1 || result.Skipped != 0 || len(result.Failed) != 0 {
+		t.Errorf("got %+v, want the broken article downloaded", result)
+	}
+}
+
+func TestDownloadCanceled(t *testing.T) {
+	openTestDB(t)
+	client := lib.NewClient("consumer-key")
+	saves := []models.PocketSave{{Id: "1", Url: "https://example.org/1"}}
+	if err := db.UpsertSaves(saves...); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := Download(ctx, client, saves, Options{}, nil)
+	if err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if result.Downloaded != 0 || len(result.Failed) != 0 {
+		t.Errorf("got %+v, want nothing stored", result)
+	}
+}
+
+func TestSiteLimiter(t *testing.T) {
+	limiter := siteLimiter{interval: 50 * time.Millisecond, next: make(map[string]time.Time)}
+	ctx := context.Background()
+	start := time.Now()
+	for _, rawUrl := range []string{"https://example.org/1", "https://www.example.org/2", "https://example.org/3"} {
+		if err := limiter.wait(ctx, rawUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3 requests to the same site took %v, want at least 2 intervals", elapsed)
+	}
+
+	start = time.Now()
+	if err := limiter.wait(ctx, "https://other.org"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("a request to another site waited %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.wait(ctx, "https://example.org/4"); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,84 @@
+package helpkeys
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+const (
+	Add          = "add"
+	Archive      = "archive"
+	Favorite     = "favorite"
+	Delete       = "delete"
+	Refresh      = "refresh"
+	Open         = "open"
+	ManageTags   = "manage_tags"
+	EditTags     = "edit_tags"
+	GetContent   = "get_content"
+	Refetch      = "refetch_content"
+	Search       = "search"
+	Download     = "download"
+	Highlight    = "highlight"
+	Highlights   = "highlights"
+	Find         = "find"
+	FindNext     = "find_next"
+	FindPrevious = "find_previous"
+	Links        = "links"
+	NextView     = "next_view"
+	PrevView     = "prev_view"
+)
+
+var bindings = map[string][]string{
+	Add:          {"a"},
+	Archive:      {"A"},
+	Favorite:     {"F"},
+	Delete:       {"D"},
+	Refresh:      {"R"},
+	Open:         {"o"},
+	ManageTags:   {"T"},
+	EditTags:     {"t"},
+	GetContent:   {"g"},
+	Refetch:      {"G"},
+	Search:       {"s"},
+	Download:     {"O"},
+	Highlight:    {"v"},
+	Highlights:   {"H"},
+	Find:         {"/"},
+	FindNext:     {"n"},
+	FindPrevious: {"N"},
+	Links:        {"l"},
+	NextView:     {"tab"},
+	PrevView:     {"shift+tab"},
+}
+
+// SetBindings replaces the default keys of the given actions
+func SetBindings(overrides map[string][]string) error {
+	unknown := make([]string, 0)
+	for action, keys := range overrides {
+		if _, ok := bindings[action]; !ok {
+			unknown = append(unknown, action)
+			continue
+		}
+		if len(keys) > 0 {
+			bindings[action] = slices.Clone(keys)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return errors.New("unknown keybindings: " + strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func Get(action string) key.Binding {
+	return key.NewBinding(key.WithKeys(bindings[action]...))
+}
+
+func WithHelp(action string, help string) key.Binding {
+	keys := bindings[action]
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keys[0], help))
+}
@@ -6,19 +6,28 @@ type ItemdetailsKeys struct {
 	Quit       key.Binding
 	Open       key.Binding
 	GetContent key.Binding
+	Refetch    key.Binding
 	Archive    key.Binding
 	Unarchive  key.Binding
+	Favorite   key.Binding
 	Delete     key.Binding
 	EditTags   key.Binding
+	Highlight  key.Binding
+	Find       key.Binding
+	Links      key.Binding
 }
 
 func (m ItemdetailsKeys) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{m.Quit},
 		{m.Open},
-		{m.Archive},
+		{m.Archive, m.Unarchive},
+		{m.Favorite},
 		{m.Delete},
 		{m.EditTags},
+		{m.Highlight},
+		{m.Find},
+		{m.Links},
 	}
 }
 
@@ -26,8 +35,31 @@ func (m ItemdetailsKeys) ShortHelp() []key.Binding {
 	return []key.Binding{
 		m.Quit,
 		m.Open,
+		m.Archive,
+		m.Unarchive,
+		m.Favorite,
 		m.Delete,
 		m.GetContent,
+		m.Refetch,
 		m.EditTags,
+		m.Highlight,
+		m.Find,
+		m.Links,
+	}
+}
+
+// HighlightKeys are the keys of the reader while selecting lines to highlight
+type HighlightKeys struct{}
+
+func (m HighlightKeys) FullHelp() [][]key.Binding {
+	return [][]key.Binding{m.ShortHelp()}
+}
+
+func (m HighlightKeys) ShortHelp() []key.Binding {
+	return []key.Binding{
+		key.NewBinding(key.WithKeys("up", "down", "k", "j"), key.WithHelp("↑/↓", "select lines")),
+		key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "highlight / edit note")),
+		key.NewBinding(key.WithKeys("x"), key.WithHelp("x", "remove highlight")),
+		key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", "cancel")),
 	}
 }
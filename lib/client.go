@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultBaseURL   = "https://getpocket.com"
+	DefaultUserAgent = "tasca"
+)
+
+const (
+	// below throttleBelow remaining requests, they are spread until the rate limit resets
+	throttleBelow = 10
+	maxThrottle   = 30 * time.Second
+)
+
+// Client calls the Pocket API, every call can be canceled through its context
+type Client struct {
+	HTTPClient  *http.Client
+	BaseURL     string
+	ConsumerKey string
+	UserAgent   string
+	Retry       RetryPolicy
+	limit       rateLimit
+}
+
+// rateLimit tracks the X-Limit-* headers of the last response
+type rateLimit struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	resetAt   time.Time
+}
+
+func NewClient(consumerKey string) *Client {
+	return &Client{
+		HTTPClient:  &http.Client{},
+		BaseURL:     DefaultBaseURL,
+		ConsumerKey: consumerKey,
+		UserAgent:   DefaultUserAgent,
+		Retry:       DefaultRetryPolicy,
+	}
+}
+
+// postJSON sends body to the endpoint along with the consumer key and the access token, if any
+func (c *Client) postJSON(ctx context.Context, path string, accessToken string, body map[string]any) (*http.Response, error) {
+	body["consumer_key"] = c.ConsumerKey
+	if accessToken != "" {
+		body["access_token"] = accessToken
+	}
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(path), bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return c.do(request)
+}
+
+func (c *Client) postForm(ctx context.Context, path string, values url.Values) (*http.Response, error) {
+	values.Set("consumer_key", c.ConsumerKey)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url(path), strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(request)
+}
+
+// do sends a request to Pocket, respecting its rate limit
+func (c *Client) do(request *http.Request) (*http.Response, error) {
+	if err := c.limit.wait(request.Context()); err != nil {
+		return nil, err
+	}
+	response, err := c.send(request)
+	if err == nil {
+		c.limit.update(response.Header)
+	}
+	return response, err
+}
+
+func (c *Client) send(request *http.Request) (*http.Response, error) {
+	if c.UserAgent != "" {
+		request.Header.Set("User-Agent", c.UserAgent)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return httpClient.Do(request)
+}
+
+func (l *rateLimit) update(header http.Header) {
+	remaining, reset := rateLimitHeaders(header)
+	if remaining < 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known = true
+	l.remaining = remaining
+	l.resetAt = time.Now().Add(reset)
+}
+
+// wait delays the request when few are left before the rate limit is reached
+// and fails without calling Pocket when none are
+func (l *rateLimit) wait(ctx context.Context) error {
+	l.mu.Lock()
+	until := time.Until(l.resetAt)
+	if !l.known || until <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	remaining := l.remaining
+	if remaining > 0 {
+		l.remaining--
+	}
+	l.mu.Unlock()
+
+	if remaining <= 0 {
+		return &APIError{Message: "Pocket rate limit reached", RetryAfter: until, kind: ErrRateLimited}
+	}
+	if remaining >= throttleBelow {
+		return nil
+	}
+	timer := time.NewTimer(min(until/time.Duration(remaining), maxThrottle))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *Client) url(path string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + path
+}
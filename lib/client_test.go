@@ -0,0 +1,47 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient returns a client calling the handler, without retries
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client := NewClient("consumer-key")
+	client.BaseURL = server.URL
+	client.Retry = RetryPolicy{Attempts: 1}
+	return client
+}
+
+// decodeBody decodes the JSON body of a request to the Pocket API
+func decodeBody(t *testing.T, r *http.Request) map[string]any {
+	t.Helper()
+	body := make(map[string]any)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Errorf("could not decode request body: %v", err)
+	}
+	return body
+}
+
+func TestPostJSONSendsCredentials(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body := decodeBody(t, r)
+		if body["consumer_key"] != "consumer-key" || body["access_token"] != "token" {
+			t.Errorf("unexpected credentials %v, %v", body["consumer_key"], body["access_token"])
+		}
+		if ua := r.Header.Get("User-Agent"); ua != DefaultUserAgent {
+			t.Errorf("User-Agent = %q, want %q", ua, DefaultUserAgent)
+		}
+	})
+	response, err := client.postJSON(context.Background(), "/v3/get", "token", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+}
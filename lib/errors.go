@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid or expired access token")
+	ErrInvalidConsumerKey = errors.New("invalid consumer key")
+	ErrRateLimited        = errors.New("rate limit exceeded")
+	ErrMaintenance        = errors.New("Pocket is down for maintenance")
+)
+
+// APIError is an error returned by the Pocket API, it wraps one of the Err* errors when
+// its cause is known so that it can be checked with errors.Is
+type APIError struct {
+	// Op is what was being done, e.g. "retrieve saves"
+	Op         string
+	StatusCode int
+	Status     string
+	// Code and Message come from the X-Error-Code and X-Error headers
+	Code    int
+	Message string
+	// RetryAfter is how long to wait before calling the API again when rate limited
+	RetryAfter time.Duration
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	msg := e.Status
+	if e.Message != "" && msg != "" {
+		msg += ": " + e.Message
+	} else if e.Message != "" {
+		msg = e.Message
+	}
+	if e.Op == "" {
+		return msg
+	}
+	return fmt.Sprintf("could not %s: %s", e.Op, msg)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+// checkResponse returns an *APIError if the request was not successful
+func checkResponse(op string, response *http.Response) error {
+	if response.StatusCode == http.StatusOK {
+		return nil
+	}
+	err := &APIError{
+		Op:         op,
+		StatusCode: response.StatusCode,
+		Status:     response.Status,
+		Message:    response.Header.Get("X-Error"),
+	}
+	err.Code, _ = strconv.Atoi(response.Header.Get("X-Error-Code"))
+	remaining, reset := rateLimitHeaders(response.Header)
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests,
+		response.StatusCode == http.StatusForbidden && remaining == 0:
+		err.kind = ErrRateLimited
+		err.RetryAfter = reset
+	case response.StatusCode == http.StatusUnauthorized, err.Code == 107:
+		err.kind = ErrInvalidToken
+	case err.Code == 138, err.Code == 152:
+		err.kind = ErrInvalidConsumerKey
+	case response.StatusCode == http.StatusServiceUnavailable, err.Code == 199:
+		err.kind = ErrMaintenance
+	}
+	return err
+}
+
+// rateLimitHeaders returns the requests left before the user or consumer key limit is reached,
+// whichever is closer, and the time until it resets. remaining is -1 if Pocket did not send it.
+func rateLimitHeaders(header http.Header) (remaining int, reset time.Duration) {
+	remaining = -1
+	for _, prefix := range []string{"X-Limit-User-", "X-Limit-Key-"} {
+		r, err := strconv.Atoi(header.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		if remaining == -1 || r < remaining {
+			remaining = r
+			seconds, _ := strconv.Atoi(header.Get(prefix + "Reset"))
+			reset = time.Duration(seconds) * time.Second
+		}
+	}
+	return
+}
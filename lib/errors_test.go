@@ -0,0 +1,73 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		header     map[string]string
+		kind       error
+		code       int
+		retryAfter time.Duration
+	}{
+		{name: "unauthorized", status: 401, kind: ErrInvalidToken},
+		{name: "token mismatch", status: 400, header: map[string]string{"X-Error-Code": "107"}, kind: ErrInvalidToken, code: 107},
+		{name: "invalid consumer key", status: 403, header: map[string]string{"X-Error-Code": "152"}, kind: ErrInvalidConsumerKey, code: 152},
+		{name: "consumer key revoked", status: 403, header: map[string]string{"X-Error-Code": "138"}, kind: ErrInvalidConsumerKey, code: 138},
+		{name: "maintenance", status: 503, kind: ErrMaintenance},
+		{name: "server issue", status: 500, header: map[string]string{"X-Error-Code": "199"}, kind: ErrMaintenance, code: 199},
+		{name: "too many requests", status: 429, kind: ErrRateLimited},
+		{
+			name:   "user rate limit",
+			status: 403,
+			header: map[string]string{
+				"X-Limit-User-Remaining": "0",
+				"X-Limit-User-Reset":     "60",
+				"X-Limit-Key-Remaining":  "5000",
+				"X-Limit-Key-Reset":      "3000",
+			},
+			kind:       ErrRateLimited,
+			retryAfter: time.Minute,
+		},
+		{name: "forbidden with requests left", status: 403, header: map[string]string{"X-Limit-User-Remaining": "10"}},
+		{name: "bad request", status: 400, header: map[string]string{"X-Error-Code": "130"}, code: 130},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &http.Response{StatusCode: tt.status, Status: http.StatusText(tt.status), Header: make(http.Header)}
+			response.Header.Set("X-Error", "details")
+			for k, v := range tt.header {
+				response.Header.Set(k, v)
+			}
+			err := checkResponse("test", response)
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got %v, want an *APIError", err)
+			}
+			if tt.kind != nil && !errors.Is(err, tt.kind) {
+				t.Errorf("got %v, want %v", err, tt.kind)
+			}
+			if tt.kind == nil && apiErr.Unwrap() != nil {
+				t.Errorf("got kind %v, want none", apiErr.Unwrap())
+			}
+			if apiErr.Code != tt.code || apiErr.StatusCode != tt.status || apiErr.Message != "details" {
+				t.Errorf("got code %d status %d message %q", apiErr.Code, apiErr.StatusCode, apiErr.Message)
+			}
+			if apiErr.RetryAfter != tt.retryAfter {
+				t.Errorf("RetryAfter = %v, want %v", apiErr.RetryAfter, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestCheckResponseOk(t *testing.T) {
+	if err := checkResponse("test", &http.Response{StatusCode: http.StatusOK}); err != nil {
+		t.Errorf("got %v, want nil", err)
+	}
+}
@@ -1,35 +1,33 @@
 package lib
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io"
-	"net/http"
 	"net/url"
-	"strconv"
 	"strings"
+	"time"
 
 	uuid "github.com/google/uuid"
-	"github.com/thomas-introini/pocket-cli/config"
 	"github.com/thomas-introini/pocket-cli/models"
 	"github.com/thomas-introini/pocket-cli/utils"
 )
 
-const POCKET_URL = "https://getpocket.com"
-
-func GetRequestToken(redirectURI string) (code string, state string, err error) {
-	consumerKey := config.GetConfig().PocketConsumerKey
+func (c *Client) GetRequestToken(ctx context.Context, redirectURI string) (code string, state string, err error) {
 	uuid, err := uuid.NewRandom()
 	if err != nil {
 		return
 	}
 	state = uuid.String()
-	resp, err := http.PostForm(POCKET_URL+"/v3/oauth/request", url.Values{"consumer_key": {consumerKey}, "state": {state}, "redirect_uri": {redirectURI}})
+	resp, err := c.postForm(ctx, "/v3/oauth/request", url.Values{"state": {state}, "redirect_uri": {redirectURI}})
 	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
+	if err = checkResponse("get request token", resp); err != nil {
+		return
+	}
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return
@@ -51,17 +49,15 @@ func GetRequestToken(redirectURI string) (code string, state string, err error)
 	return
 }
 
-func GetAccesToken(state string, code string) (accessToken string, username string, err error) {
-	consumerKey := config.GetConfig().PocketConsumerKey
-	resp, err := http.PostForm(POCKET_URL+"/v3/oauth/authorize", url.Values{"consumer_key": {consumerKey}, "code": {code}})
+func (c *Client) GetAccessToken(ctx context.Context, state string, code string) (accessToken string, username string, err error) {
+	resp, err := c.postForm(ctx, "/v3/oauth/authorize", url.Values{"code": {code}})
 	if err != nil {
 		return
 	}
-	if resp.StatusCode != http.StatusOK {
-		err = errors.New("get access token: status code " + resp.Status)
+	defer resp.Body.Close()
+	if err = checkResponse("get access token", resp); err != nil {
 		return
 	}
-	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return
@@ -89,118 +85,240 @@ func GetAccesToken(state string, code string) (accessToken string, username stri
 
 }
 
-func OpenAuthorizationURL(requestToken string, redirectURI string) error {
-	err := utils.OpenInBrowser(POCKET_URL + "/auth/authorize?request_token=" + requestToken + "&redirect_uri=" + redirectURI)
+func (c *Client) OpenAuthorizationURL(requestToken string, redirectURI string) error {
+	err := utils.OpenInBrowser(c.url("/auth/authorize") + "?request_token=" + requestToken + "&redirect_uri=" + redirectURI)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-type PocketSavesResponse struct {
-	Since float64
-	Saves []models.PocketSave
+const (
+	ActionAdd         = "add"
+	ActionArchive     = "archive"
+	ActionReadd       = "readd"
+	ActionFavorite    = "favorite"
+	ActionUnfavorite  = "unfavorite"
+	ActionDelete      = "delete"
+	ActionTagsAdd     = "tags_add"
+	ActionTagsRemove  = "tags_remove"
+	ActionTagsReplace = "tags_replace"
+	ActionTagsClear   = "tags_clear"
+	ActionTagRename   = "tag_rename"
+	ActionTagDelete   = "tag_delete"
+)
+
+type Action struct {
+	Action string `json:"action"`
+	ItemId string `json:"item_id,omitempty"`
+	Time   int64  `json:"time,omitempty"`
+	Tags   string `json:"tags,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	OldTag string `json:"old_tag,omitempty"`
+	NewTag string `json:"new_tag,omitempty"`
+	Url    string `json:"url,omitempty"`
+	Title  string `json:"title,omitempty"`
 }
 
-func GetAllPocketSaves(accessToken string, since float64) (PocketSavesResponse, error) {
-	consumerKey := config.GetConfig().PocketConsumerKey
-	body := map[string]any{
-		"consumer_key": consumerKey,
-		"access_token": accessToken,
-		"state":        "all",
-		"sort":         "newest",
-		"detailType":   "complete",
-		"since":        since,
-	}
-	jsonBody, err := json.Marshal(body)
+type ActionResult struct {
+	Ok    bool
+	Item  json.RawMessage
+	Error string
+}
+
+type sendResponse struct {
+	Status        int               `json:"status"`
+	ActionResults []json.RawMessage `json:"action_results"`
+	ActionErrors  []*struct {
+		Message string `json:"message"`
+	} `json:"action_errors"`
+}
+
+func NewModifyActions(action string, ids ...string) []Action {
+	now := time.Now().Unix()
+	actions := make([]Action, 0, len(ids))
+	for _, id := range ids {
+		actions = append(actions, Action{Action: action, ItemId: id, Time: now})
+	}
+	return actions
+}
+
+func (c *Client) ModifySaves(ctx context.Context, accessToken string, action string, ids ...string) ([]bool, error) {
+	return c.SendActions(ctx, accessToken, NewModifyActions(action, ids...))
+}
+
+func NewTagsActions(action string, tags []string, ids ...string) []Action {
+	actions := NewModifyActions(action, ids...)
+	if action != ActionTagsClear {
+		for i := range actions {
+			actions[i].Tags = strings.Join(tags, ",")
+		}
+	}
+	return actions
+}
+
+func NewAddAction(saveUrl string, title string, tags []string) Action {
+	return Action{
+		Action: ActionAdd,
+		Url:    saveUrl,
+		Title:  title,
+		Tags:   strings.Join(tags, ","),
+		Time:   time.Now().Unix(),
+	}
+}
+
+func (c *Client) EditTags(ctx context.Context, accessToken string, action string, tags []string, ids ...string) ([]bool, error) {
+	return c.SendActions(ctx, accessToken, NewTagsActions(action, tags, ids...))
+}
+
+// NewTagRenameAction renames the tag on all the saves
+func NewTagRenameAction(oldTag string, newTag string) Action {
+	return Action{Action: ActionTagRename, OldTag: oldTag, NewTag: newTag, Time: time.Now().Unix()}
+}
+
+// NewTagDeleteAction removes the tag from all the saves
+func NewTagDeleteAction(tag string) Action {
+	return Action{Action: ActionTagDelete, Tag: tag, Time: time.Now().Unix()}
+}
+
+func (c *Client) RenameTag(ctx context.Context, accessToken string, oldTag string, newTag string) error {
+	results, err := c.SendActions(ctx, accessToken, []Action{NewTagRenameAction(oldTag, newTag)})
 	if err != nil {
-		return PocketSavesResponse{}, err
+		return err
+	}
+	if !results[0] {
+		return errors.New("could not rename tag " + oldTag)
 	}
+	return nil
+}
 
-	response, err := http.Post(POCKET_URL+"/v3/get", "application/json", bytes.NewBuffer(jsonBody))
+func (c *Client) DeleteTag(ctx context.Context, accessToken string, tag string) error {
+	results, err := c.SendActions(ctx, accessToken, []Action{NewTagDeleteAction(tag)})
 	if err != nil {
-		return PocketSavesResponse{}, err
+		return err
 	}
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return PocketSavesResponse{}, errors.New("could not retrieve saves: " + response.Status)
+	if !results[0] {
+		return errors.New("could not delete tag " + tag)
+	}
+	return nil
+}
+
+func (c *Client) SendActions(ctx context.Context, accessToken string, actions []Action) ([]bool, error) {
+	results, err := c.SendActionsResults(ctx, accessToken, actions)
+	if err != nil {
+		return nil, err
+	}
+	oks := make([]bool, len(results))
+	for i, result := range results {
+		oks[i] = result.Ok
 	}
+	return oks, nil
+}
 
-	var jsonResponse map[string]interface{}
+func (c *Client) SendActionsResults(ctx context.Context, accessToken string, actions []Action) ([]ActionResult, error) {
+	response, err := c.postJSON(ctx, "/v3/send", accessToken, map[string]any{"actions": actions})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if err = checkResponse("send actions", response); err != nil {
+		return nil, err
+	}
 
+	var jsonResponse sendResponse
 	err = json.NewDecoder(response.Body).Decode(&jsonResponse)
 	if err != nil {
-		return PocketSavesResponse{}, err
-	}
-	since = jsonResponse["since"].(float64)
-	list := jsonResponse["list"].(map[string]interface{})
-	saves := make([]models.PocketSave, 0)
-	for _, save := range list {
-		save := save.(map[string]interface{})
-		updatedOn, err := strconv.Atoi(save["time_updated"].(string))
-		if err != nil {
-			return PocketSavesResponse{}, err
-		}
-		addedOn, err := strconv.Atoi(save["time_added"].(string))
-		if err != nil {
-			return PocketSavesResponse{}, err
+		return nil, err
+	}
+	if len(jsonResponse.ActionResults) != len(actions) {
+		return nil, errors.New("could not send actions: unexpected number of results")
+	}
+	results := make([]ActionResult, len(actions))
+	for i, raw := range jsonResponse.ActionResults {
+		result := ActionResult{}
+		if err := json.Unmarshal(raw, &result.Ok); err != nil {
+			// some actions (e.g. add) return the affected item instead of a boolean
+			result.Ok = string(raw) != "null"
+			result.Item = raw
 		}
-		e := save["excerpt"]
-		excerpt := ""
-		if e != nil {
-			excerpt = e.(string)
+		if i < len(jsonResponse.ActionErrors) && jsonResponse.ActionErrors[i] != nil {
+			result.Error = jsonResponse.ActionErrors[i].Message
 		}
+		results[i] = result
+	}
+	return results, nil
+}
 
-		givenTitle := save["given_title"]
-		resolvedTitle := save["resolved_title"]
-		title := "Untitled"
-		if resolvedTitle != nil && resolvedTitle != "" {
-			title = resolvedTitle.(string)
-		} else if givenTitle != nil {
-			title = givenTitle.(string)
-		}
+type addedItem struct {
+	ItemId  string `json:"item_id"`
+	Title   string `json:"title"`
+	Excerpt string `json:"excerpt"`
+}
 
-		var timeToRead uint16
-		if ttr := save["time_to_read"]; ttr != nil && ttr != "" {
-			timeToRead = uint16(ttr.(float64))
-		}
-		var favorite int
-		if fvrt := save["favorite"]; fvrt != nil && fvrt != "" {
-			if favorite, err = strconv.Atoi(fvrt.(string)); err != nil {
-				return PocketSavesResponse{}, err
-			}
-		}
-		var status int
-		if st := save["status"]; st != nil && st != "" {
-			if status, err = strconv.Atoi(st.(string)); err != nil {
-				return PocketSavesResponse{}, err
-			}
-		}
-		tagList := make([]string, 0)
-		tags := save["tags"]
-		if tags != nil {
-			tagMap := tags.(map[string]interface{})
-			for tag := range tagMap {
-				tagList = append(tagList, tag)
-			}
-		}
+type addResponse struct {
+	Status int       `json:"status"`
+	Item   addedItem `json:"item"`
+}
+
+func (c *Client) AddSave(ctx context.Context, accessToken string, saveUrl string, title string, tags []string) (models.PocketSave, error) {
+	body := map[string]any{
+		"url": saveUrl,
+	}
+	if title != "" {
+		body["title"] = title
+	}
+	if len(tags) > 0 {
+		body["tags"] = strings.Join(tags, ",")
+	}
+	response, err := c.postJSON(ctx, "/v3/add", accessToken, body)
+	if err != nil {
+		return models.PocketSave{}, err
+	}
+	defer response.Body.Close()
+	if err = checkResponse("add save", response); err != nil {
+		return models.PocketSave{}, err
+	}
+
+	var jsonResponse addResponse
+	err = json.NewDecoder(response.Body).Decode(&jsonResponse)
+	if err != nil {
+		return models.PocketSave{}, err
+	}
+	return jsonResponse.Item.save(saveUrl, title, tags)
+}
+
+// ParseAddedSave returns the save created by an add action sent with SendActionsResults
+func ParseAddedSave(action Action, result ActionResult) (models.PocketSave, error) {
+	var item addedItem
+	if err := json.Unmarshal(result.Item, &item); err != nil {
+		return models.PocketSave{}, err
+	}
+	tags := make([]string, 0)
+	if action.Tags != "" {
+		tags = strings.Split(action.Tags, ",")
+	}
+	return item.save(action.Url, action.Title, tags)
+}
 
-		saves = append(saves, models.PocketSave{
-			Id:              save["item_id"].(string),
-			SaveTitle:       title,
-			Url:             save["given_url"].(string),
-			SaveDescription: excerpt,
-			TimeToRead:      timeToRead,
-			Favorite:        favorite == 1,
-			Status:          uint8(status),
-			Tags:            strings.Join(tagList, ","),
-			AddedOn:         uint32(addedOn),
-			UpdatedOn:       uint32(updatedOn),
-		})
-	}
-
-	return PocketSavesResponse{
-		Since: since,
-		Saves: saves,
+func (item addedItem) save(saveUrl string, title string, tags []string) (models.PocketSave, error) {
+	if item.ItemId == "" {
+		return models.PocketSave{}, errors.New("could not add save: missing item id")
+	}
+
+	if item.Title != "" {
+		title = item.Title
+	} else if title == "" {
+		title = "Untitled"
+	}
+	now := uint32(time.Now().Unix())
+	return models.PocketSave{
+		Id:              item.ItemId,
+		SaveTitle:       title,
+		Url:             saveUrl,
+		SaveDescription: item.Excerpt,
+		Status:          models.StatusOK,
+		Tags:            tags,
+		AddedOn:         now,
+		UpdatedOn:       now,
 	}, nil
 }
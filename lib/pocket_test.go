@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSendActionsResults(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v3/send" {
+			t.Errorf("path = %s, want /v3/send", r.URL.Path)
+		}
+		actions, _ := decodeBody(t, r)["actions"].([]any)
+		if len(actions) != 4 {
+			t.Errorf("sent %d actions, want 4", len(actions))
+		}
+		fmt.Fprint(w, `{"status":1,
+			"action_results":[true,{"item_id":"42","title":"Added","excerpt":"text"},false,null],
+			"action_errors":[null,null,{"message":"Invalid item","code":422},null]}`)
+	})
+
+	actions := []Action{
+		{Action: ActionArchive, ItemId: "1"},
+		NewAddAction("https://example.com/a", "", []string{"go", "tools"}),
+		{Action: ActionFavorite, ItemId: "2"},
+		NewAddAction("https://example.com/b", "", nil),
+	}
+	results, err := client.SendActionsResults(context.Background(), "token", actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oks := make([]bool, len(results))
+	for i, result := range results {
+		oks[i] = result.Ok
+	}
+	if fmt.Sprint(oks) != "[true true false false]" {
+		t.Errorf("results = %v, want [true true false false]", oks)
+	}
+	if results[2].Error != "Invalid item" {
+		t.Errorf("error = %q, want the message of action_errors", results[2].Error)
+	}
+
+	if string(results[1].Item) == "" {
+		t.Error("the item of the add result is missing")
+	}
+}
+
+func TestSendActionsResultsCountMismatch(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":1,"action_results":[true]}`)
+	})
+	actions := []Action{{Action: ActionArchive, ItemId: "1"}, {Action: ActionArchive, ItemId: "2"}}
+	if _, err := client.SendActionsResults(context.Background(), "token", actions); err == nil {
+		t.Error("expected an error when Pocket does not return a result per action")
+	}
+}
@@ -1,15 +1,57 @@
 package lib
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-shiori/go-readability"
+	"github.com/thomas-introini/pocket-cli/models"
 )
 
-func GetArticleContent(url string) (string, error) {
-	article, err := readability.FromURL(url, 30*time.Second)
+const articleTimeout = 30 * time.Second
+
+// GetArticle downloads the page of a save and extracts its readable content
+func (c *Client) GetArticle(ctx context.Context, saveId string, articleUrl string) (models.Article, error) {
+	parsedUrl, err := url.ParseRequestURI(articleUrl)
+	if err != nil {
+		return models.Article{}, err
+	}
+	var article readability.Article
+	err = c.Retry.Do(ctx, func() error {
+		ctx, cancel := context.WithTimeout(ctx, articleTimeout)
+		defer cancel()
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, articleUrl, nil)
+		if err != nil {
+			return err
+		}
+		response, err := c.send(request)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return &APIError{Op: "get article content", StatusCode: response.StatusCode, Status: response.Status}
+		}
+		if !strings.Contains(response.Header.Get("Content-Type"), "text/html") {
+			return errors.New("could not get article content: not an HTML document")
+		}
+		article, err = readability.FromReader(response.Body, parsedUrl)
+		return err
+	})
 	if err != nil {
-		return "", err
+		return models.Article{}, err
 	}
-	return article.TextContent, nil
+	return models.Article{
+		SaveId:    saveId,
+		Url:       articleUrl,
+		Text:      article.TextContent,
+		Html:      article.Content,
+		Byline:    article.Byline,
+		SiteName:  article.SiteName,
+		FetchedOn: time.Now().Unix(),
+	}, nil
 }
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy retries idempotent requests failed because of server or network errors
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts, 1 disables retries
+	Attempts int
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	// OnRetry is called with the number of the next attempt before waiting for it
+	OnRetry func(attempt, attempts int, err error)
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts: 5,
+	MinDelay: 500 * time.Millisecond,
+	MaxDelay: 30 * time.Second,
+}
+
+// Do calls fn until it succeeds, it fails with an error that is not worth retrying
+// or the attempts are over
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || ctx.Err() != nil || !IsRetryable(err) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt+1, p.Attempts, err)
+		}
+		timer := time.NewTimer(p.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// delay doubles at each attempt, with a random jitter so that clients do not retry in sync
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.MinDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// IsRetryable tells whether the request may succeed if sent again:
+// on server errors, timeouts and dropped connections
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 && !errors.Is(err, ErrMaintenance)
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
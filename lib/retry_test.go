@@ -0,0 +1,68 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	serverErr := &APIError{StatusCode: 502}
+	badRequest := &APIError{StatusCode: 400}
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int
+		wantErr error
+	}{
+		{name: "success", errs: []error{nil}, calls: 1},
+		{name: "success after server errors", errs: []error{serverErr, serverErr, nil}, calls: 3},
+		{name: "attempts over", errs: []error{serverErr, serverErr, serverErr, serverErr}, calls: 3, wantErr: serverErr},
+		{name: "not retryable", errs: []error{badRequest, nil}, calls: 1, wantErr: badRequest},
+		{name: "maintenance", errs: []error{&APIError{StatusCode: 503, kind: ErrMaintenance}, nil}, calls: 1, wantErr: ErrMaintenance},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			retries := make([]int, 0)
+			policy := RetryPolicy{
+				Attempts: 3,
+				OnRetry: func(attempt, attempts int, err error) {
+					retries = append(retries, attempt)
+				},
+			}
+			err := policy.Do(context.Background(), func() error {
+				err := tt.errs[calls]
+				calls++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("got %d calls, want %d", calls, tt.calls)
+			}
+			if len(retries) != calls-1 {
+				t.Errorf("OnRetry called for %v, want once per retry", retries)
+			}
+			for i, attempt := range retries {
+				if attempt != i+2 {
+					t.Errorf("OnRetry called with attempt %d, want %d", attempt, i+2)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := RetryPolicy{Attempts: 5}.Do(ctx, func() error {
+		calls++
+		cancel()
+		return &APIError{StatusCode: 502}
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("got %v after %d calls, want the error of the only call", err, calls)
+	}
+}
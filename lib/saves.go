@@ -0,0 +1,316 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// PageSize is the number of saves requested to /v3/get at a time
+const PageSize = 500
+
+type PocketSavesResponse struct {
+	Since float64
+	Saves []models.PocketSave
+}
+
+type PocketSavesPage struct {
+	Since  float64
+	Offset int
+	// Total is the number of saves changed since the requested time, across all the pages
+	Total int
+	Saves []models.PocketSave
+}
+
+// numeric decodes the numbers Pocket sends either as JSON numbers or as strings
+type numeric int64
+
+func (n *numeric) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*n = 0
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*n = numeric(f)
+	return nil
+}
+
+type pocketItem struct {
+	ItemId        string          `json:"item_id"`
+	GivenUrl      string          `json:"given_url"`
+	GivenTitle    string          `json:"given_title"`
+	ResolvedTitle string          `json:"resolved_title"`
+	Excerpt       string          `json:"excerpt"`
+	TimeToRead    numeric         `json:"time_to_read"`
+	Favorite      numeric         `json:"favorite"`
+	Status        numeric         `json:"status"`
+	TimeAdded     numeric         `json:"time_added"`
+	TimeUpdated   numeric         `json:"time_updated"`
+	Tags          json.RawMessage `json:"tags"`
+	ResolvedUrl   string          `json:"resolved_url"`
+	WordCount     numeric         `json:"word_count"`
+	Lang          string          `json:"lang"`
+	TopImageUrl   string          `json:"top_image_url"`
+	// authors and images are objects keyed by id, or empty arrays
+	Authors        json.RawMessage `json:"authors"`
+	Images         json.RawMessage `json:"images"`
+	DomainMetadata struct {
+		Name string `json:"name"`
+	} `json:"domain_metadata"`
+	IsArticle      numeric `json:"is_article"`
+	HasVideo       numeric `json:"has_video"`
+	ListenDuration numeric `json:"listen_duration_estimate"`
+}
+
+type pocketAuthor struct {
+	Name string `json:"name"`
+}
+
+type pocketImage struct {
+	Src string `json:"src"`
+}
+
+func (item pocketItem) save() models.PocketSave {
+	if item.Status == models.StatusDeleted {
+		// deleted saves only have the id and the status
+		return models.PocketSave{Id: item.ItemId, Status: models.StatusDeleted}
+	}
+	title := "Untitled"
+	if item.ResolvedTitle != "" {
+		title = item.ResolvedTitle
+	} else if item.GivenTitle != "" {
+		title = item.GivenTitle
+	}
+	tags := make([]string, 0)
+	var tagMap map[string]json.RawMessage
+	if json.Unmarshal(item.Tags, &tagMap) == nil {
+		for tag := range tagMap {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+	}
+	return models.PocketSave{
+		Id:              item.ItemId,
+		SaveTitle:       title,
+		Url:             item.GivenUrl,
+		SaveDescription: item.Excerpt,
+		TimeToRead:      uint16(item.TimeToRead),
+		Favorite:        item.Favorite == 1,
+		Status:          uint8(item.Status),
+		Tags:            tags,
+		AddedOn:         uint32(item.TimeAdded),
+		UpdatedOn:       uint32(item.TimeUpdated),
+		ResolvedUrl:     item.ResolvedUrl,
+		WordCount:       uint32(item.WordCount),
+		Lang:            item.Lang,
+		TopImageUrl:     item.TopImageUrl,
+		Authors: byId(item.Authors, func(a pocketAuthor) string {
+			return a.Name
+		}),
+		Images: byId(item.Images, func(i pocketImage) string {
+			return i.Src
+		}),
+		Domain:         item.domain(),
+		IsArticle:      item.IsArticle == 1,
+		HasVideo:       item.HasVideo > 0,
+		ListenDuration: uint32(item.ListenDuration),
+	}
+}
+
+// domain returns the name of the site the save belongs to, or its host when Pocket does not know it
+func (item pocketItem) domain() string {
+	if item.DomainMetadata.Name != "" {
+		return item.DomainMetadata.Name
+	}
+	rawUrl := item.ResolvedUrl
+	if rawUrl == "" {
+		rawUrl = item.GivenUrl
+	}
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
+}
+
+// byId decodes an object keyed by numeric ids into a list ordered by id,
+// skipping the empty values
+func byId[T any](raw json.RawMessage, value func(T) string) []string {
+	list := make([]string, 0)
+	var items map[string]T
+	if json.Unmarshal(raw, &items) != nil {
+		return list
+	}
+	ids := make([]string, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := strconv.Atoi(ids[i])
+		b, _ := strconv.Atoi(ids[j])
+		return a < b
+	})
+	for _, id := range ids {
+		if v := value(items[id]); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func (c *Client) GetAllPocketSaves(ctx context.Context, accessToken string, since float64) (PocketSavesResponse, error) {
+	saves := make([]models.PocketSave, 0)
+	since, err := c.GetPocketSavesPages(ctx, accessToken, since, func(page PocketSavesPage) error {
+		saves = append(saves, page.Saves...)
+		return nil
+	})
+	if err != nil {
+		return PocketSavesResponse{}, err
+	}
+	return PocketSavesResponse{Since: since, Saves: saves}, nil
+}
+
+// GetPocketSavesPages fetches the saves changed since the given time PageSize saves at a time,
+// calling onPage as soon as each page is decoded. It returns the time of the first page,
+// to be used as since by the next call so that changes made while paginating are not lost.
+func (c *Client) GetPocketSavesPages(ctx context.Context, accessToken string, since float64, onPage func(PocketSavesPage) error) (float64, error) {
+	first := since
+	for offset := 0; ; {
+		var page PocketSavesPage
+		err := c.Retry.Do(ctx, func() (err error) {
+			page, err = c.getPocketSavesPage(ctx, accessToken, since, offset)
+			return
+		})
+		if err != nil {
+			return first, err
+		}
+		if offset == 0 {
+			first = page.Since
+		}
+		if err = onPage(page); err != nil {
+			return first, err
+		}
+		offset += len(page.Saves)
+		if len(page.Saves) == 0 || (page.Total > 0 && offset >= page.Total) || (page.Total == 0 && len(page.Saves) < PageSize) {
+			return first, nil
+		}
+	}
+}
+
+func (c *Client) getPocketSavesPage(ctx context.Context, accessToken string, since float64, offset int) (PocketSavesPage, error) {
+	body := map[string]any{
+		"state":      "all",
+		"sort":       "newest",
+		"detailType": "complete",
+		"since":      since,
+		"count":      PageSize,
+		"offset":     offset,
+		"total":      "1",
+	}
+	response, err := c.postJSON(ctx, "/v3/get", accessToken, body)
+	if err != nil {
+		return PocketSavesPage{}, err
+	}
+	defer response.Body.Close()
+	if err = checkResponse("retrieve saves", response); err != nil {
+		return PocketSavesPage{}, err
+	}
+
+	page, err := decodePocketSavesPage(response.Body)
+	if err != nil {
+		return page, fmt.Errorf("could not retrieve saves: %w", err)
+	}
+	page.Offset = offset
+	return page, nil
+}
+
+// decodePocketSavesPage decodes the /v3/get response one save at a time
+// instead of loading the whole list in memory
+func decodePocketSavesPage(r io.Reader) (PocketSavesPage, error) {
+	page := PocketSavesPage{Saves: make([]models.PocketSave, 0)}
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return page, err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return page, err
+		}
+		var value numeric
+		switch token {
+		case "since":
+			err = dec.Decode(&value)
+			page.Since = float64(value)
+		case "total":
+			err = dec.Decode(&value)
+			page.Total = int(value)
+		case "list":
+			err = decodeList(dec, func(item pocketItem) {
+				page.Saves = append(page.Saves, item.save())
+			})
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return page, err
+		}
+	}
+	return page, expectDelim(dec, '}')
+}
+
+// decodeList decodes the list of saves, an object keyed by item id
+// or an empty array when there are no saves
+func decodeList(dec *json.Decoder, onItem func(pocketItem)) error {
+	token, err := dec.Token()
+	if err != nil || token == nil {
+		return err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok || (delim != '{' && delim != '[') {
+		return fmt.Errorf("unexpected list %v", token)
+	}
+	for dec.More() {
+		id := ""
+		if delim == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			id, _ = key.(string)
+		}
+		var item pocketItem
+		if err = dec.Decode(&item); err != nil {
+			return err
+		}
+		if item.ItemId == "" {
+			item.ItemId = id
+		}
+		onItem(item)
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func expectDelim(dec *json.Decoder, expected json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != expected {
+		return fmt.Errorf("expected %v, found %v", expected, token)
+	}
+	return nil
+}
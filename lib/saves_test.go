@@ -0,0 +1,99 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetPocketSavesPages(t *testing.T) {
+	offsets := make([]float64, 0)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		body := decodeBody(t, r)
+		offset := body["offset"].(float64)
+		offsets = append(offsets, offset)
+		if body["since"] != float64(10) {
+			t.Errorf("since = %v, want 10 on every page", body["since"])
+		}
+		switch offset {
+		case 0:
+			fmt.Fprint(w, `{"status":1,"since":100,"total":"3","list":{
+				"1":{"item_id":"1","given_url":"https://a.com","status":"0"},
+				"2":{"item_id":"2","given_url":"https://b.com","status":"1"}}}`)
+		case 2:
+			fmt.Fprint(w, `{"status":1,"since":200,"total":"3","list":{
+				"3":{"given_url":"https://c.com","status":"2"}}}`)
+		default:
+			t.Errorf("unexpected offset %v", offset)
+		}
+	})
+
+	pages := make([]PocketSavesPage, 0)
+	since, err := client.GetPocketSavesPages(context.Background(), "token", 10, func(page PocketSavesPage) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if since != 100 {
+		t.Errorf("since = %v, want the time of the first page", since)
+	}
+	if len(pages) != 2 || len(offsets) != 2 {
+		t.Fatalf("got %d pages for offsets %v, want 2", len(pages), offsets)
+	}
+	if pages[1].Offset != 2 || pages[1].Total != 3 {
+		t.Errorf("second page offset %d total %d, want 2 and 3", pages[1].Offset, pages[1].Total)
+	}
+	ids := make([]string, 0)
+	for _, page := range pages {
+		for _, save := range page.Saves {
+			ids = append(ids, save.Id)
+		}
+	}
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Errorf("ids = %v, want [1 2 3], the key is the id of items without item_id", ids)
+	}
+}
+
+func TestGetPocketSavesPagesEmptyList(t *testing.T) {
+	calls := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"status":2,"complete":1,"list":[],"error":null,"search_meta":{"search_type":"normal"},"since":50}`)
+	})
+
+	saves := -1
+	since, err := client.GetPocketSavesPages(context.Background(), "token", 10, func(page PocketSavesPage) error {
+		saves = len(page.Saves)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || saves != 0 {
+		t.Errorf("got %d calls and %d saves, want 1 call without saves", calls, saves)
+	}
+	if since != 50 {
+		t.Errorf("since = %v, want 50", since)
+	}
+}
+
+func TestGetPocketSavesPagesError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Error-Code", "107")
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	since, err := client.GetPocketSavesPages(context.Background(), "token", 10, func(page PocketSavesPage) error {
+		t.Error("no page expected")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if since != 10 {
+		t.Errorf("since = %v, want the requested one on errors", since)
+	}
+}
@@ -6,15 +6,20 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/joho/godotenv"
+	"github.com/thomas-introini/pocket-cli/cli"
 	"github.com/thomas-introini/pocket-cli/config"
 	"github.com/thomas-introini/pocket-cli/db"
 	"github.com/thomas-introini/pocket-cli/globals"
+	"github.com/thomas-introini/pocket-cli/helpkeys"
+	"github.com/thomas-introini/pocket-cli/lib"
+	styles "github.com/thomas-introini/pocket-cli/views"
 	"github.com/thomas-introini/pocket-cli/views/root"
 )
 
 func main() {
 	err := godotenv.Load()
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("error loading .env file:", err)
 		os.Exit(2)
 	}
 	if len(os.Getenv("DEBUG")) > 0 {
@@ -26,12 +31,31 @@ func main() {
 		defer f.Close()
 	}
 
-	pocketConsumerKey := os.Getenv("POCKET_CONSUMER_KEY")
-	if pocketConsumerKey == "" {
-		fmt.Println("set POCKET_CONSUMER_KEY environment variable")
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Println("error loading config:", err)
+		os.Exit(1)
+	}
+	config.InitConfig(cfg)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err = cli.RunConfig(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "tasca:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.PocketConsumerKey == "" {
+		fmt.Println("set consumer_key in " + config.Path() + " or the POCKET_CONSUMER_KEY environment variable")
+		os.Exit(1)
+	}
+	if err = styles.SetTheme(cfg.Theme); err != nil {
+		fmt.Println("error loading config:", err)
+		os.Exit(1)
+	}
+	if err = helpkeys.SetBindings(cfg.Keybindings); err != nil {
+		fmt.Println("error loading config:", err)
 		os.Exit(1)
 	}
-	config.InitConfig(pocketConsumerKey)
 	err = db.ConnectDB()
 	if err != nil {
 		fmt.Println("error connecting to database:", err)
@@ -42,7 +66,19 @@ func main() {
 		fmt.Println("Error while retrieving user from database:", err)
 		os.Exit(1)
 	}
-	p := tea.NewProgram(root.New(user), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	client := lib.NewClient(cfg.PocketConsumerKey)
+	if cfg.PocketURL != "" {
+		client.BaseURL = cfg.PocketURL
+	}
+	client.Retry.Attempts = cfg.RetryAttempts
+	if len(os.Args) > 1 {
+		if err = cli.Run(client, user, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "tasca:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	p := tea.NewProgram(root.New(client, user), tea.WithAltScreen(), tea.WithMouseCellMotion())
 	globals.InitProgram(p)
 	if _, err = p.Run(); err != nil {
 		fmt.Println("Could not run the program", err)
@@ -1,5 +1,7 @@
 package models
 
+import "strconv"
+
 var NoUser = PocketUser{}
 
 type PocketUser struct {
@@ -22,9 +24,22 @@ type PocketSave struct {
 	TimeToRead      uint16
 	Favorite        bool
 	Status          uint8
-	Tags            string
+	Tags            []string
 	AddedOn         uint32
 	UpdatedOn       uint32
+	ResolvedUrl     string
+	WordCount       uint32
+	Lang            string
+	TopImageUrl     string
+	Authors         []string
+	Images          []string
+	Domain          string
+	IsArticle       bool
+	HasVideo        bool
+	// ListenDuration is the estimated time to listen to the save, in seconds
+	ListenDuration uint32
+	// ReadProgress is how much of the downloaded article has been read, in percent
+	ReadProgress int
 }
 
 type ByAddedOnDesc []PocketSave
@@ -39,6 +54,12 @@ func (i PocketSave) Title() (title string) {
 	} else {
 		title = i.SaveTitle
 	}
+	if i.Favorite {
+		title = "★ " + title
+	}
+	if i.ReadProgress > 0 && i.ReadProgress < 100 {
+		title += " · " + strconv.Itoa(i.ReadProgress) + "%"
+	}
 	return
 }
 
@@ -50,3 +71,42 @@ func (i PocketSave) Description() string {
 	}
 }
 func (i PocketSave) FilterValue() string { return i.SaveTitle }
+
+// Article is the readable content extracted from the page of a save
+type Article struct {
+	SaveId    string
+	Url       string
+	Text      string
+	Html      string
+	Byline    string
+	SiteName  string
+	FetchedOn int64
+}
+
+// Highlight is a passage of the article of a save, found in the article by its text
+type Highlight struct {
+	Id        int64
+	SaveId    string
+	Text      string
+	Note      string
+	CreatedOn int64
+}
+
+type SaveHighlights struct {
+	Save       PocketSave
+	Highlights []Highlight
+}
+
+type PocketTag struct {
+	Name  string
+	Count int
+}
+
+func (t PocketTag) Title() string { return "#" + t.Name }
+func (t PocketTag) Description() string {
+	if t.Count == 1 {
+		return "1 save"
+	}
+	return strconv.Itoa(t.Count) + " saves"
+}
+func (t PocketTag) FilterValue() string { return t.Name }
@@ -0,0 +1,357 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// saves added while offline get a local id until Pocket assigns them one
+const localIdPrefix = "local-"
+
+const (
+	minBackoff = 5 * time.Second
+	maxBackoff = 10 * time.Minute
+)
+
+var replaying sync.Mutex
+
+func IsLocal(id string) bool {
+	return strings.HasPrefix(id, localIdPrefix)
+}
+
+// Modify applies the action to the local saves and queues it,
+// it returns the saves as they are after the action
+func Modify(action string, saves ...models.PocketSave) ([]models.PocketSave, error) {
+	modified := make([]models.PocketSave, 0, len(saves))
+	ids := make([]string, 0, len(saves))
+	for _, save := range saves {
+		switch action {
+		case lib.ActionArchive:
+			save.Status = models.StatusArchived
+		case lib.ActionReadd:
+			save.Status = models.StatusOK
+		case lib.ActionFavorite:
+			save.Favorite = true
+		case lib.ActionUnfavorite:
+			save.Favorite = false
+		case lib.ActionDelete:
+			save.Status = models.StatusDeleted
+		default:
+			return nil, errors.New("outbox: unsupported action " + action)
+		}
+		modified = append(modified, save)
+		ids = append(ids, save.Id)
+	}
+	var change db.LocalChange
+	switch action {
+	case lib.ActionArchive:
+		change = db.SavesStatusChange(models.StatusArchived, ids...)
+	case lib.ActionReadd:
+		change = db.SavesStatusChange(models.StatusOK, ids...)
+	case lib.ActionFavorite:
+		change = db.SavesFavoriteChange(true, ids...)
+	case lib.ActionUnfavorite:
+		change = db.SavesFavoriteChange(false, ids...)
+	case lib.ActionDelete:
+		change = db.DeleteSavesChange(ids...)
+	}
+	if err := queue(change, lib.NewModifyActions(action, ids...)...); err != nil {
+		return nil, err
+	}
+	return modified, nil
+}
+
+// EditTags sets the tags of the save locally and queues the tags added and removed,
+// so that the tags added to the save elsewhere in the meantime are kept
+func EditTags(save models.PocketSave, tags []string) (models.PocketSave, error) {
+	added, removed := diffTags(save.Tags, tags)
+	actions := make([]lib.Action, 0, 2)
+	if len(added) > 0 {
+		actions = append(actions, lib.NewTagsActions(lib.ActionTagsAdd, added, save.Id)...)
+	}
+	if len(removed) > 0 {
+		actions = append(actions, lib.NewTagsActions(lib.ActionTagsRemove, removed, save.Id)...)
+	}
+	if err := queue(db.SavesTagsChange(tags, save.Id), actions...); err != nil {
+		return save, err
+	}
+	save.Tags = tags
+	return save, nil
+}
+
+// ReplaceTags replaces the tags of the save as a whole, locally and in Pocket
+func ReplaceTags(save models.PocketSave, tags []string) (models.PocketSave, error) {
+	action := lib.ActionTagsReplace
+	if len(tags) == 0 {
+		action = lib.ActionTagsClear
+	}
+	if err := queue(db.SavesTagsChange(tags, save.Id), lib.NewTagsActions(action, tags, save.Id)...); err != nil {
+		return save, err
+	}
+	save.Tags = tags
+	return save, nil
+}
+
+func diffTags(current, tags []string) (added, removed []string) {
+	for _, tag := range tags {
+		if !slices.Contains(current, tag) {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range current {
+		if !slices.Contains(tags, tag) {
+			removed = append(removed, tag)
+		}
+	}
+	return
+}
+
+// RenameTag renames the tag of all the saves locally and queues the change
+func RenameTag(oldTag, newTag string) error {
+	return queue(db.RenameTagChange(oldTag, newTag), lib.NewTagRenameAction(oldTag, newTag))
+}
+
+// DeleteTag removes the tag from all the saves locally and queues the change
+func DeleteTag(tag string) error {
+	return queue(db.DeleteTagChange(tag), lib.NewTagDeleteAction(tag))
+}
+
+// Add stores a new save with a local id and queues its creation
+func Add(saveUrl string, title string, tags []string) (models.PocketSave, error) {
+	now := time.Now()
+	save := models.PocketSave{
+		Id:        fmt.Sprintf("%s%d", localIdPrefix, now.UnixNano()),
+		SaveTitle: title,
+		Url:       saveUrl,
+		Status:    models.StatusOK,
+		Tags:      tags,
+		AddedOn:   uint32(now.Unix()),
+		UpdatedOn: uint32(now.Unix()),
+	}
+	action := lib.NewAddAction(saveUrl, title, tags)
+	action.ItemId = save.Id
+	if err := queue(db.UpsertSavesChange(save), action); err != nil {
+		return save, err
+	}
+	return save, nil
+}
+
+// queue adds the actions to the outbox and applies their local change, all or none of them
+func queue(change db.LocalChange, actions ...lib.Action) error {
+	queued := make([]db.QueuedAction, 0, len(actions))
+	for _, action := range actions {
+		id := action.ItemId
+		action.ItemId = ""
+		payload, err := json.Marshal(action)
+		if err != nil {
+			return err
+		}
+		queued = append(queued, db.QueuedAction{SaveId: id, Action: action.Action, Payload: string(payload)})
+	}
+	return db.QueueActions(change, queued...)
+}
+
+type ReplayResult struct {
+	Sent int
+	// Failed are the actions rejected by Pocket during this replay
+	Failed []db.PendingAction
+	// Added maps the local ids of the saves added offline to the saves created by Pocket
+	Added map[string]models.PocketSave
+	// Resync is true when failed actions changed saves that only a full sync can restore
+	Resync bool
+	// Pending is the number of actions still queued, they are retried after RetryIn
+	Pending int
+	RetryIn time.Duration
+	Err     error
+}
+
+// Replay sends the queued actions to Pocket in order. Actions rejected by Pocket are
+// marked as failed and their local changes undone, the others are retried with an
+// exponential backoff on network, server and rate limit errors.
+func Replay(ctx context.Context, client *lib.Client, accessToken string) ReplayResult {
+	replaying.Lock()
+	defer replaying.Unlock()
+
+	result := ReplayResult{Failed: make([]db.PendingAction, 0), Added: make(map[string]models.PocketSave)}
+	for {
+		pending, err := db.GetQueuedActions()
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		// actions are sent in order, a postponed action holds back the following ones
+		if len(pending) > 0 && pending[0].RetryOn > time.Now().Unix() {
+			break
+		}
+		batch, actions, err := nextBatch(pending, &result)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if len(batch) == 0 {
+			break
+		}
+		if err = send(ctx, client, accessToken, batch, actions, &result); err != nil {
+			if rejected(err) {
+				reason := err.Error()
+				for _, p := range batch {
+					if err = reject(p, reason, &result); err != nil {
+						result.Err = err
+						return result
+					}
+				}
+				continue
+			}
+			result.Err = err
+			retryIn := backoff(batch[0].Attempts + 1)
+			var apiErr *lib.APIError
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > retryIn {
+				retryIn = apiErr.RetryAfter
+			}
+			err = db.PostponePendingActions(time.Now().Add(retryIn).Unix(), err.Error(), ids(batch)...)
+			if err != nil {
+				result.Err = err
+			}
+			break
+		}
+	}
+
+	queued, err := db.GetQueuedActions()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Pending = len(queued)
+	if len(queued) > 0 {
+		result.RetryIn = max(time.Until(time.Unix(queued[0].RetryOn, 0)), 0)
+	}
+	return result
+}
+
+// nextBatch returns the actions that can be sent together: an add ends the batch
+// since the following actions may refer to the id Pocket assigns to the new save
+func nextBatch(pending []db.PendingAction, result *ReplayResult) ([]db.PendingAction, []lib.Action, error) {
+	batch := make([]db.PendingAction, 0)
+	actions := make([]lib.Action, 0)
+	for _, p := range pending {
+		var action lib.Action
+		if err := json.Unmarshal([]byte(p.Payload), &action); err != nil {
+			if err = fail(p, "invalid action: "+err.Error(), result); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if IsLocal(p.SaveId) && action.Action != lib.ActionAdd {
+			// the add action of the save precedes it, it must have failed
+			if err := fail(p, "the save has not been added to Pocket", result); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		if action.Action != lib.ActionAdd {
+			action.ItemId = p.SaveId
+		}
+		batch = append(batch, p)
+		actions = append(actions, action)
+		if action.Action == lib.ActionAdd {
+			break
+		}
+	}
+	return batch, actions, nil
+}
+
+func send(ctx context.Context, client *lib.Client, accessToken string, batch []db.PendingAction, actions []lib.Action, result *ReplayResult) error {
+	results, err := client.SendActionsResults(ctx, accessToken, actions)
+	if err != nil {
+		return err
+	}
+	completed := make([]int64, 0, len(batch))
+	for i, r := range results {
+		p := batch[i]
+		if !r.Ok {
+			reason := r.Error
+			if reason == "" {
+				reason = "rejected by Pocket"
+			}
+			if err = reject(p, reason, result); err != nil {
+				return err
+			}
+			continue
+		}
+		if actions[i].Action == lib.ActionAdd {
+			save, err := lib.ParseAddedSave(actions[i], r)
+			if err == nil {
+				err = db.ReplaceLocalSave(p.SaveId, save)
+			}
+			if err != nil {
+				return err
+			}
+			result.Added[p.SaveId] = save
+		}
+		completed = append(completed, p.Id)
+		result.Sent++
+	}
+	return db.CompletePendingActions(completed...)
+}
+
+// rejected tells whether Pocket refused the request, sending it again would fail the same way.
+// The actions stay queued while offline, when Pocket is unavailable or the token is invalid.
+func rejected(err error) bool {
+	var apiErr *lib.APIError
+	if !errors.As(err, &apiErr) || lib.IsRetryable(err) {
+		return false
+	}
+	return !errors.Is(err, lib.ErrRateLimited) &&
+		!errors.Is(err, lib.ErrMaintenance) &&
+		!errors.Is(err, lib.ErrInvalidToken) &&
+		!errors.Is(err, lib.ErrInvalidConsumerKey)
+}
+
+// reject marks the action as failed and undoes its local change: a save added offline
+// is removed, the saves changed by the other actions are fetched again by a full sync
+func reject(p db.PendingAction, reason string, result *ReplayResult) error {
+	if err := fail(p, reason, result); err != nil {
+		return err
+	}
+	if p.Action == lib.ActionAdd {
+		return db.DeleteSaves(p.SaveId)
+	}
+	result.Resync = true
+	return db.SetSyncCursor(0)
+}
+
+// fail marks the action as failed, it is not sent again unless retried by the user
+func fail(p db.PendingAction, reason string, result *ReplayResult) error {
+	if err := db.FailPendingAction(p.Id, reason); err != nil {
+		return err
+	}
+	p.Failed, p.LastError = true, reason
+	result.Failed = append(result.Failed, p)
+	return nil
+}
+
+func backoff(attempt int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+func ids(actions []db.PendingAction) []int64 {
+	ids := make([]int64, len(actions))
+	for i, a := range actions {
+		ids[i] = a.Id
+	}
+	return ids
+}
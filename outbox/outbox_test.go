@@ -0,0 +1,282 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/thomas-introini/pocket-cli/config"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// openTestDB connects to a new database with two saves for the duration of the test
+func openTestDB(t *testing.T) {
+	t.Helper()
+	config.InitConfig(config.Config{DBPath: filepath.Join(t.TempDir(), "cache.db")})
+	if err := db.ConnectDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+	err := db.UpsertSaves(
+		models.PocketSave{Id: "1", SaveTitle: "One", Url: "https://a.com", Tags: []string{"go"}},
+		models.PocketSave{Id: "2", SaveTitle: "Two", Url: "https://b.com"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestClient returns a client sending the actions to respond, which returns
+// the body of the response to each request to /v3/send
+func newTestClient(t *testing.T, respond func(actions []lib.Action) (status int, body string)) *lib.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Actions []lib.Action `json:"actions"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("could not decode request body: %v", err)
+		}
+		status, body := respond(request.Actions)
+		if status != http.StatusOK {
+			w.Header().Set("X-Error-Code", "130")
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	client := lib.NewClient("consumer-key")
+	client.BaseURL = server.URL
+	client.Retry = lib.RetryPolicy{Attempts: 1}
+	return client
+}
+
+func getSave(t *testing.T, id string) models.PocketSave {
+	t.Helper()
+	save, err := db.GetPocketSave(id)
+	if err != nil {
+		t.Fatalf("save %s: %v", id, err)
+	}
+	return save
+}
+
+func TestReplay(t *testing.T) {
+	openTestDB(t)
+	requests := make([][]lib.Action, 0)
+	client := newTestClient(t, func(actions []lib.Action) (int, string) {
+		requests = append(requests, actions)
+		if len(requests) == 1 {
+			return http.StatusOK, `{"status":1,
+				"action_results":[true,false,{"item_id":"42","title":"Added"}],
+				"action_errors":[null,{"message":"Invalid item"},null]}`
+		}
+		return http.StatusOK, `{"status":1,"action_results":[true]}`
+	})
+
+	if _, err := Modify(lib.ActionArchive, getSave(t, "1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Modify(lib.ActionFavorite, getSave(t, "2")); err != nil {
+		t.Fatal(err)
+	}
+	added, err := Add("https://c.com", "", []string{"new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = EditTags(added, []string{"new", "later"}); err != nil {
+		t.Fatal(err)
+	}
+	if getSave(t, "1").Status != models.StatusArchived || !getSave(t, "2").Favorite {
+		t.Fatal("the actions are not applied locally")
+	}
+
+	result := Replay(context.Background(), client, "token")
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2 since the add ends the batch", len(requests))
+	}
+	if requests[1][0].Action != lib.ActionTagsAdd || requests[1][0].ItemId != "42" {
+		t.Errorf("got %+v, want the tags added to the id assigned by Pocket", requests[1][0])
+	}
+	if result.Sent != 3 || len(result.Failed) != 1 || result.Failed[0].SaveId != "2" {
+		t.Errorf("got %d sent and failed %+v, want 3 sent and the favorite failed", result.Sent, result.Failed)
+	}
+	if !result.Resync || result.Pending != 0 {
+		t.Errorf("got resync %v and %d pending, want a resync and no pending action", result.Resync, result.Pending)
+	}
+	if result.Added[added.Id].Id != "42" {
+		t.Errorf("got added %+v, want the local save mapped to 42", result.Added)
+	}
+
+	save := getSave(t, "42")
+	if save.SaveTitle != "Added" || len(save.Tags) != 2 {
+		t.Errorf("got save %+v, want the local save with the new id", save)
+	}
+	if _, err = db.GetPocketSave(added.Id); err == nil {
+		t.Error("the save with the local id still exists")
+	}
+	failed, err := db.GetFailedActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0].LastError != "Invalid item" {
+		t.Errorf("got failed actions %+v", failed)
+	}
+}
+
+func TestReplayExistingSave(t *testing.T) {
+	openTestDB(t)
+	client := newTestClient(t, func(actions []lib.Action) (int, string) {
+		// the url was already saved, Pocket returns the existing save
+		return http.StatusOK, `{"status":1,"action_results":[{"item_id":"1","title":"One"}]}`
+	})
+	added, err := Add("https://a.com", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := Replay(context.Background(), client, "token")
+	if result.Err != nil || result.Sent != 1 {
+		t.Fatalf("got %d sent, err %v", result.Sent, result.Err)
+	}
+	saves, err := db.GetPocketSaves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saves) != 2 {
+		t.Errorf("got %d saves, want the local one merged into the existing one", len(saves))
+	}
+	if _, err = db.GetPocketSave(added.Id); err == nil {
+		t.Error("the save with the local id still exists")
+	}
+}
+
+func TestReplayPostpones(t *testing.T) {
+	openTestDB(t)
+	calls := 0
+	client := newTestClient(t, func(actions []lib.Action) (int, string) {
+		calls++
+		return http.StatusServiceUnavailable, ""
+	})
+	if _, err := Modify(lib.ActionArchive, getSave(t, "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	result := Replay(context.Background(), client, "token")
+	if result.Err == nil || result.Pending != 1 || result.RetryIn <= 0 || len(result.Failed) != 0 {
+		t.Fatalf("got %+v, want the action postponed", result)
+	}
+	// the postponed action is not sent before RetryIn
+	result = Replay(context.Background(), client, "token")
+	if calls != 1 || result.Pending != 1 {
+		t.Errorf("got %d calls and %d pending, want 1 call and the action still pending", calls, result.Pending)
+	}
+	if getSave(t, "1").Status != models.StatusArchived {
+		t.Error("the local change of the postponed action is undone")
+	}
+}
+
+func TestReplayRejected(t *testing.T) {
+	openTestDB(t)
+	client := newTestClient(t, func(actions []lib.Action) (int, string) {
+		return http.StatusBadRequest, ""
+	})
+	added, err := Add("https://c.com", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Modify(lib.ActionArchive, getSave(t, "1")); err != nil {
+		t.Fatal(err)
+	}
+
+	result := Replay(context.Background(), client, "token")
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if len(result.Failed) != 2 || result.Pending != 0 || !result.Resync {
+		t.Errorf("got %+v, want both actions failed and a resync", result)
+	}
+	if _, err = db.GetPocketSave(added.Id); err == nil {
+		t.Error("the save added offline is not removed")
+	}
+}
+
+func TestNextBatch(t *testing.T) {
+	openTestDB(t)
+	pending := []db.PendingAction{
+		{Id: 1, SaveId: "1", Action: lib.ActionArchive, Payload: `{"action":"archive"}`},
+		{Id: 2, SaveId: "2", Action: lib.ActionArchive, Payload: `{`},
+		{Id: 3, SaveId: "local-1", Action: lib.ActionFavorite, Payload: `{"action":"favorite"}`},
+		{Id: 4, SaveId: "local-2", Action: lib.ActionAdd, Payload: `{"action":"add","url":"https://c.com"}`},
+		{Id: 5, SaveId: "local-2", Action: lib.ActionFavorite, Payload: `{"action":"favorite"}`},
+	}
+	result := ReplayResult{}
+	batch, actions, err := nextBatch(pending, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 2 || batch[0].Id != 1 || batch[1].Id != 4 {
+		t.Fatalf("got batch %+v, want the archive and the add", batch)
+	}
+	if actions[0].ItemId != "1" || actions[1].ItemId != "" || actions[1].Url != "https://c.com" {
+		t.Errorf("got actions %+v", actions)
+	}
+	if len(result.Failed) != 2 || result.Failed[0].Id != 2 || result.Failed[1].Id != 3 {
+		t.Errorf("got failed %+v, want the invalid action and the one of a save not added", result.Failed)
+	}
+}
+
+func TestQueueRollsBack(t *testing.T) {
+	openTestDB(t)
+	// the tags of a missing save violate the foreign key of save_tag
+	missing := models.PocketSave{Id: "missing", Url: "https://d.com"}
+	if _, err := EditTags(missing, []string{"go"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	pending, failed, err := db.CountPendingActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 || failed != 0 {
+		t.Errorf("got %d pending actions, want none queued without its local change", pending)
+	}
+}
+
+func TestEditTags(t *testing.T) {
+	openTestDB(t)
+	if _, err := EditTags(getSave(t, "1"), []string{"go", "cli"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EditTags(getSave(t, "1"), []string{"cli"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplaceTags(getSave(t, "1"), nil); err != nil {
+		t.Fatal(err)
+	}
+	queued, err := db.GetQueuedActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0, len(queued))
+	for _, p := range queued {
+		var action lib.Action
+		if err = json.Unmarshal([]byte(p.Payload), &action); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, action.Action+" "+action.Tags)
+	}
+	want := []string{"tags_add cli", "tags_remove go", "tags_clear "}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if tags := getSave(t, "1").Tags; len(tags) != 0 {
+		t.Errorf("got tags %v, want none", tags)
+	}
+}
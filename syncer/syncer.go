@@ -0,0 +1,116 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+var syncing sync.Mutex
+
+// Result holds the changes applied to the local saves by a sync
+type Result struct {
+	Since   int64
+	Added   []models.PocketSave
+	Updated []models.PocketSave
+	Removed []models.PocketSave
+}
+
+// Changes returns all the saves changed by the sync, removed ones have StatusDeleted
+func (r Result) Changes() []models.PocketSave {
+	changes := make([]models.PocketSave, 0, len(r.Added)+len(r.Updated)+len(r.Removed))
+	changes = append(changes, r.Added...)
+	changes = append(changes, r.Updated...)
+	return append(changes, r.Removed...)
+}
+
+func (r Result) String() string {
+	counts := make([]string, 0)
+	if len(r.Added) > 0 {
+		counts = append(counts, fmt.Sprintf("%d added", len(r.Added)))
+	}
+	if len(r.Updated) > 0 {
+		counts = append(counts, fmt.Sprintf("%d updated", len(r.Updated)))
+	}
+	if len(r.Removed) > 0 {
+		counts = append(counts, fmt.Sprintf("%d removed", len(r.Removed)))
+	}
+	if len(counts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(counts, ", ")
+}
+
+// Progress is called after each page of saves is stored with the number of saves
+// fetched so far and the total number of saves changed on Pocket
+type Progress func(done, total int)
+
+// Sync fetches the saves changed on Pocket since the last sync, or all of them on
+// the first one, applies them to the local saves and moves the sync cursor forward.
+// Pages are stored as they arrive, the cursor only moves once all of them are.
+func Sync(ctx context.Context, client *lib.Client, accessToken string, progress Progress) (Result, error) {
+	syncing.Lock()
+	defer syncing.Unlock()
+
+	since, err := db.GetSyncCursor()
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{
+		Added:   make([]models.PocketSave, 0),
+		Updated: make([]models.PocketSave, 0),
+		Removed: make([]models.PocketSave, 0),
+	}
+	next, err := client.GetPocketSavesPages(ctx, accessToken, float64(since), func(page lib.PocketSavesPage) error {
+		if err := result.apply(page.Saves); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(page.Offset+len(page.Saves), max(page.Total, page.Offset+len(page.Saves)))
+		}
+		return nil
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	if err = db.SetSyncCursor(int64(next)); err != nil {
+		return Result{}, err
+	}
+	result.Since = int64(next)
+	sort.Sort(models.ByAddedOnDesc(result.Added))
+	sort.Sort(models.ByAddedOnDesc(result.Updated))
+	return result, nil
+}
+
+func (r *Result) apply(saves []models.PocketSave) error {
+	ids := make([]string, len(saves))
+	for i, save := range saves {
+		ids[i] = save.Id
+	}
+	existing, err := db.GetExistingSaveIds(ids...)
+	if err != nil {
+		return err
+	}
+	if err = db.InsertSaves(saves); err != nil {
+		return err
+	}
+	for _, save := range saves {
+		switch {
+		case save.Status == models.StatusDeleted && existing[save.Id]:
+			r.Removed = append(r.Removed, save)
+		case save.Status == models.StatusDeleted:
+			// deleted before ever being synced
+		case existing[save.Id]:
+			r.Updated = append(r.Updated, save)
+		default:
+			r.Added = append(r.Added, save)
+		}
+	}
+	return nil
+}
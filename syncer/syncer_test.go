@@ -0,0 +1,130 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/thomas-introini/pocket-cli/config"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/lib"
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// openTestDB connects to a new database with two saves synced at time 100
+func openTestDB(t *testing.T) {
+	t.Helper()
+	config.InitConfig(config.Config{DBPath: filepath.Join(t.TempDir(), "cache.db")})
+	if err := db.ConnectDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.DB.Close() })
+	_, err := db.DB.Exec("INSERT INTO user(username, access_token, saves_updated_on) VALUES ('user', 'token', 100)")
+	if err == nil {
+		err = db.UpsertSaves(
+			models.PocketSave{Id: "1", SaveTitle: "One", Url: "https://a.com"},
+			models.PocketSave{Id: "2", SaveTitle: "Two", Url: "https://b.com"},
+		)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newTestClient returns a client getting the pages returned by respond for each offset
+func newTestClient(t *testing.T, respond func(since float64, offset int) (status int, body string)) *lib.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Since  float64 `json:"since"`
+			Offset int     `json:"offset"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("could not decode request body: %v", err)
+		}
+		status, body := respond(request.Since, request.Offset)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	client := lib.NewClient("consumer-key")
+	client.BaseURL = server.URL
+	client.Retry = lib.RetryPolicy{Attempts: 1}
+	return client
+}
+
+func ids(saves []models.PocketSave) string {
+	ids := make([]string, len(saves))
+	for i, save := range saves {
+		ids[i] = save.Id
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestSync(t *testing.T) {
+	openTestDB(t)
+	client := newTestClient(t, func(since float64, offset int) (int, string) {
+		if since != 100 {
+			t.Errorf("since = %v, want the cursor of the last sync", since)
+		}
+		return http.StatusOK, `{"status":1,"since":200,"total":"4","list":{
+			"1":{"item_id":"1","given_url":"https://a.com","resolved_title":"One","status":"1"},
+			"2":{"item_id":"2","status":"2"},
+			"3":{"item_id":"3","given_url":"https://c.com","resolved_title":"Three","status":"0","time_added":"10"},
+			"4":{"item_id":"4","status":"2"}}}`
+	})
+
+	done := make([]int, 0)
+	result, err := Sync(context.Background(), client, "token", func(d, total int) {
+		done = append(done, d)
+		if total != 4 {
+			t.Errorf("total = %d, want 4", total)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(result.Added) != "[3]" || ids(result.Updated) != "[1]" || ids(result.Removed) != "[2]" {
+		t.Errorf("got added %s updated %s removed %s", ids(result.Added), ids(result.Updated), ids(result.Removed))
+	}
+	if fmt.Sprint(done) != "[4]" {
+		t.Errorf("got progress %v, want [4]", done)
+	}
+	if since, _ := db.GetSyncCursor(); since != 200 || result.Since != 200 {
+		t.Errorf("cursor = %d, want 200", since)
+	}
+
+	saves, err := db.GetPocketSavesBy(db.AllSaves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids(saves) != "[3 1]" || saves[1].Status != models.StatusArchived {
+		t.Errorf("got saves %+v, want 3 and 1 archived", saves)
+	}
+}
+
+func TestSyncFailedPage(t *testing.T) {
+	openTestDB(t)
+	client := newTestClient(t, func(since float64, offset int) (int, string) {
+		if offset > 0 {
+			return http.StatusServiceUnavailable, ""
+		}
+		return http.StatusOK, `{"status":1,"since":200,"total":"2","list":{
+			"3":{"item_id":"3","given_url":"https://c.com","status":"0"}}}`
+	})
+
+	if _, err := Sync(context.Background(), client, "token", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	// the stored page is fetched again by the next sync
+	if since, _ := db.GetSyncCursor(); since != 100 {
+		t.Errorf("cursor = %d, want it unchanged", since)
+	}
+	if _, err := db.GetPocketSave("3"); err != nil {
+		t.Errorf("the first page is not stored: %v", err)
+	}
+}
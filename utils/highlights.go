@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/thomas-introini/pocket-cli/models"
+)
+
+// HighlightsMarkdown exports the highlights as a Markdown document, grouped by save
+func HighlightsMarkdown(saves []models.SaveHighlights) string {
+	var b strings.Builder
+	b.WriteString("# Highlights\n")
+	for _, s := range saves {
+		title := s.Save.SaveTitle
+		if title == "" {
+			title = s.Save.Url
+		}
+		b.WriteString("\n## [" + title + "](" + s.Save.Url + ")\n")
+		for _, h := range s.Highlights {
+			b.WriteString("\n> " + strings.Join(strings.Fields(h.Text), " ") + "\n")
+			if note := strings.TrimSpace(h.Note); note != "" {
+				b.WriteString("\n" + note + "\n")
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,167 @@
+package highlights
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thomas-introini/pocket-cli/models"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+var promptStyle = lipgloss.NewStyle().PaddingLeft(2)
+
+type OpenSaveCmd struct {
+	Save      models.PocketSave
+	Highlight models.Highlight
+}
+
+type DeleteHighlightCmd struct {
+	Highlight models.Highlight
+}
+
+type ExportCmd struct {
+}
+
+type CloseCmd struct {
+}
+
+// item is a highlight along with the save it belongs to
+type item struct {
+	save      models.PocketSave
+	highlight models.Highlight
+}
+
+func (i item) Title() string {
+	return strings.Join(strings.Fields(i.highlight.Text), " ")
+}
+
+func (i item) Description() string {
+	title := i.save.SaveTitle
+	if title == "" {
+		title = i.save.Url
+	}
+	if i.highlight.Note != "" {
+		return "✎ " + i.highlight.Note + " · " + title
+	}
+	return title
+}
+
+func (i item) FilterValue() string {
+	return i.highlight.Text + " " + i.highlight.Note + " " + i.save.SaveTitle
+}
+
+type window struct {
+	width  int
+	height int
+}
+
+type Model struct {
+	window     window
+	list       list.Model
+	confirming bool
+	selected   item
+}
+
+func New() Model {
+	id := list.NewDefaultDelegate()
+	id.Styles.SelectedTitle = styles.SelectedItemTitleStyle
+	id.Styles.SelectedDesc = styles.SelectedItemDescriptionStyle
+
+	list := list.New(make([]list.Item, 0), id, 10, 10)
+	list.DisableQuitKeybindings()
+	list.SetShowTitle(false)
+	list.SetStatusBarItemName("highlight", "highlights")
+	list.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(
+				key.WithKeys("enter"),
+				key.WithHelp("Enter", "Read"),
+			),
+			key.NewBinding(
+				key.WithKeys("e"),
+				key.WithHelp("e", "Export as Markdown"),
+			),
+			key.NewBinding(
+				key.WithKeys("D"),
+				key.WithHelp("D", "Delete"),
+			),
+			key.NewBinding(
+				key.WithKeys("esc"),
+				key.WithHelp("esc", "Back"),
+			),
+		}
+	}
+	return Model{list: list}
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.window.width, m.window.height = msg.Width, msg.Height-3
+		m.list.SetSize(msg.Width, msg.Height-5)
+		return m, nil
+	case tea.KeyMsg:
+		if m.confirming {
+			m.confirming = false
+			if msg.String() == "y" {
+				highlight := m.selected.highlight
+				return m, func() tea.Msg {
+					return DeleteHighlightCmd{Highlight: highlight}
+				}
+			}
+			return m, nil
+		}
+		if m.list.FilterState() != list.Filtering {
+			selected, ok := m.list.SelectedItem().(item)
+			switch msg.String() {
+			case "esc":
+				if m.list.FilterState() == list.Unfiltered {
+					return m, func() tea.Msg { return CloseCmd{} }
+				}
+			case "enter":
+				if ok {
+					return m, func() tea.Msg {
+						return OpenSaveCmd{Save: selected.save, Highlight: selected.highlight}
+					}
+				}
+			case "e":
+				return m, func() tea.Msg { return ExportCmd{} }
+			case "D":
+				if ok {
+					m.selected = selected
+					m.confirming = true
+					return m, nil
+				}
+			}
+		}
+	}
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m Model) View() string {
+	view := m.list.View() + "\n"
+	if m.confirming {
+		view += promptStyle.Render(styles.TitleBoldRedStyle.Render("Delete the highlight?") + " " + styles.TitleRedStyle.Render("(y/n)"))
+	}
+	return view
+}
+
+func (m *Model) SetHighlights(saves []models.SaveHighlights) {
+	items := make([]list.Item, 0)
+	for _, s := range saves {
+		for _, h := range s.Highlights {
+			items = append(items, item{save: s.Save, highlight: h})
+		}
+	}
+	m.list.SetItems(items)
+}
@@ -0,0 +1,81 @@
+package itemdetail
+
+import (
+	"fmt"
+	"strings"
+
+	md "github.com/JohannesKaufmann/html-to-markdown"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thomas-introini/pocket-cli/models"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+// articleMarkdown converts the HTML of the article to Markdown, links are replaced by
+// numbered references listed at the end of the article and returned in order
+func articleMarkdown(article models.Article) (string, []string, error) {
+	if article.Html == "" {
+		return article.Text, []string{}, nil
+	}
+	links := make([]string, 0)
+	domain := md.DomainFromURL(article.Url)
+	converter := md.NewConverter(domain, true, nil)
+	converter.AddRules(md.Rule{
+		Filter: []string{"a"},
+		Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+			href, ok := selec.Attr("href")
+			href = strings.TrimSpace(href)
+			if !ok || href == "" || strings.HasPrefix(href, "#") {
+				return &content
+			}
+			links = append(links, options.GetAbsoluteURL(selec, href, domain))
+			text := md.AddSpaceIfNessesary(selec, fmt.Sprintf("%s[%d]", strings.TrimSpace(content), len(links)))
+			return &text
+		},
+	})
+	markdown, err := converter.ConvertString(article.Html)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(links) > 0 {
+		markdown += "\n\n---\n\n## Links\n\n"
+		for i, link := range links {
+			markdown += fmt.Sprintf("%d. <%s>\n", i+1, link)
+		}
+	}
+	return markdown, links, nil
+}
+
+// renderMarkdown styles the Markdown for the terminal, wrapping it at width
+func renderMarkdown(markdown string, width int) (string, error) {
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStyles(articleStyle()),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		return "", err
+	}
+	// tabs are one character for the wrapping but wider on screen
+	rendered, err := renderer.Render(strings.ReplaceAll(markdown, "\t", "    "))
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(rendered, "\n"), nil
+}
+
+func articleStyle() ansi.StyleConfig {
+	style := glamour.LightStyleConfig
+	if lipgloss.HasDarkBackground() {
+		style = glamour.DarkStyleConfig
+	}
+	// the viewport has its own margin
+	margin := uint(0)
+	style.Document.Margin = &margin
+	accent := string(styles.Accent)
+	style.Heading.Color = &accent
+	style.H1.BackgroundColor = &accent
+	style.Link.Color = &accent
+	return style
+}
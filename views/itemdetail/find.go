@@ -0,0 +1,181 @@
+package itemdetail
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+var (
+	matchStyle        = lipgloss.NewStyle().Underline(true).Bold(true)
+	currentMatchStyle = lipgloss.NewStyle().Reverse(true).Bold(true)
+)
+
+// match is an occurrence of the searched text in a line of the rendered article,
+// start and end are rune offsets in the line without styles
+type match struct {
+	line  int
+	start int
+	end   int
+}
+
+// finder searches text in the article
+type finder struct {
+	input   textinput.Model
+	typing  bool
+	matches []match
+	current int
+}
+
+func newFinder() finder {
+	input := textinput.New()
+	input.Prompt = "/"
+	input.PromptStyle = styles.TitleBoldRedStyle
+	input.CharLimit = 256
+	return finder{input: input}
+}
+
+func (f finder) active() bool {
+	return f.typing || f.input.Value() != ""
+}
+
+// updateFind handles the keys while the searched text is typed
+func (m Model) updateFind(msg tea.KeyMsg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg.String() {
+	case "esc":
+		m.ClearFind()
+		return m, nil
+	case "enter":
+		m.finder.typing = false
+		m.finder.input.Blur()
+		return m, nil
+	}
+	m.finder.input, cmd = m.finder.input.Update(msg)
+	m.findMatches()
+	// the first match is the one after the top of the screen, if any
+	m.finder.current = 0
+	_, offsets := articleLines(m)
+	header := strings.Count(getHeader(m), "\n")
+	for i, match := range m.finder.matches {
+		if header+offsets[match.line] >= m.viewport.YOffset {
+			m.finder.current = i
+			break
+		}
+	}
+	m.viewport.SetContent(getViewportContent(m))
+	m.scrollToMatch()
+	return m, cmd
+}
+
+func (m *Model) startFind() tea.Cmd {
+	m.finder.typing = true
+	m.finder.input.SetValue("")
+	m.finder.matches = nil
+	m.viewport.SetContent(getViewportContent(*m))
+	return m.finder.input.Focus()
+}
+
+// ClearFind stops searching in the article, it returns false if no text was searched
+func (m *Model) ClearFind() bool {
+	if !m.finder.active() {
+		return false
+	}
+	m.finder.typing = false
+	m.finder.input.Blur()
+	m.finder.input.SetValue("")
+	m.finder.matches = nil
+	m.viewport.SetContent(getViewportContent(*m))
+	return true
+}
+
+// IsFinding tells whether text is being searched in the article
+func (m Model) IsFinding() bool {
+	return m.finder.active()
+}
+
+// FindView shows the searched text and the position of the current match
+func (m Model) FindView() string {
+	view := m.finder.input.View()
+	if !m.finder.typing {
+		view = styles.TitleBoldRedStyle.Render("/") + m.finder.input.Value()
+	}
+	count := "no matches"
+	if len(m.finder.matches) > 0 {
+		count = fmt.Sprintf("%d/%d", m.finder.current+1, len(m.finder.matches))
+	}
+	view += "  " + styles.TitleRedStyle.Render(count)
+	if !m.finder.typing {
+		view += styles.HintStyle.Render("  n next • N previous • esc clear")
+	}
+	return lipgloss.NewStyle().PaddingLeft(2).Render(view)
+}
+
+// nextMatch moves to the next match, or to the previous one when delta is -1
+func (m *Model) nextMatch(delta int) {
+	if len(m.finder.matches) == 0 {
+		return
+	}
+	m.finder.current = (m.finder.current + delta + len(m.finder.matches)) % len(m.finder.matches)
+	m.viewport.SetContent(getViewportContent(*m))
+	m.scrollToMatch()
+}
+
+func (m *Model) scrollToMatch() {
+	if len(m.finder.matches) == 0 {
+		return
+	}
+	_, offsets := articleLines(*m)
+	line := strings.Count(getHeader(*m), "\n") + offsets[m.finder.matches[m.finder.current].line]
+	if line < m.viewport.YOffset || line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(max(line-m.viewport.Height/3, 0))
+	}
+}
+
+// findMatches searches the text, ignoring case, in the lines of the rendered article
+func (m *Model) findMatches() {
+	m.finder.matches = make([]match, 0)
+	query := []rune(strings.ToLower(m.finder.input.Value()))
+	if len(query) == 0 {
+		return
+	}
+	for i, line := range plainLines(m.rendered) {
+		runes := []rune(strings.ToLower(line))
+		if len(runes) != len([]rune(line)) {
+			continue
+		}
+		for start := 0; start+len(query) <= len(runes); start++ {
+			if string(runes[start:start+len(query)]) == string(query) {
+				m.finder.matches = append(m.finder.matches, match{line: i, start: start, end: start + len(query)})
+				start += len(query) - 1
+			}
+		}
+	}
+	m.finder.current = min(m.finder.current, max(len(m.finder.matches)-1, 0))
+}
+
+// styleMatches renders the line without its styles but the one given, along with its matches
+func styleMatches(plain string, matches []match, current *match, style lipgloss.Style) string {
+	runes := []rune(plain)
+	var b strings.Builder
+	at := 0
+	for _, match := range matches {
+		if match.start > at {
+			b.WriteString(style.Render(string(runes[at:match.start])))
+		}
+		s := matchStyle
+		if current != nil && *current == match {
+			s = currentMatchStyle
+		}
+		b.WriteString(s.Render(string(runes[match.start:match.end])))
+		at = match.end
+	}
+	if at < len(runes) {
+		b.WriteString(style.Render(strings.TrimRight(string(runes[at:]), " ")))
+	}
+	return b.String()
+}
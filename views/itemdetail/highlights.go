@@ -0,0 +1,292 @@
+package itemdetail
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/muesli/reflow/wordwrap"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/models"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+var (
+	highlightStyle = lipgloss.NewStyle().Background(lipgloss.AdaptiveColor{Light: "#fff1a8", Dark: "#4d4000"})
+	selectionStyle = lipgloss.NewStyle().Reverse(true)
+	ansiEscape     = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
+)
+
+type highlightsResult struct {
+	saveId     string
+	highlights []models.Highlight
+	err        error
+}
+
+// selection is the range of lines of the rendered article being highlighted
+type selection struct {
+	active bool
+	anchor int
+	cursor int
+}
+
+func (s selection) lines() (from, to int) {
+	return min(s.anchor, s.cursor), max(s.anchor, s.cursor)
+}
+
+type noteEditor struct {
+	open  bool
+	input textinput.Model
+	text  string
+}
+
+func newNoteEditor() noteEditor {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.PromptStyle = styles.TitleRedStyle
+	input.Placeholder = "note (optional)"
+	input.CharLimit = 1024
+	return noteEditor{input: input}
+}
+
+func (e *noteEditor) Open(text, note string, width int) tea.Cmd {
+	e.open = true
+	e.text = text
+	e.input.Width = width
+	e.input.SetValue(note)
+	e.input.CursorEnd()
+	return e.input.Focus()
+}
+
+func (e *noteEditor) Close() {
+	e.open = false
+	e.input.Blur()
+}
+
+func (e noteEditor) View() string {
+	view := styles.TitleBoldRedStyle.Render("Highlight") + "\n\n"
+	view += styles.HintStyle.Render(truncate.StringWithTail(e.text, uint(e.input.Width), "…")) + "\n\n"
+	view += e.input.View()
+	return styles.ModalStyle.Render(view)
+}
+
+// updateSelection handles the keys while lines are being selected
+func (m Model) updateSelection(msg tea.KeyMsg) (Model, tea.Cmd) {
+	from, to := m.selection.lines()
+	switch msg.String() {
+	case "esc":
+		m.selection.active = false
+		m.viewport.SetContent(getViewportContent(m))
+	case "up", "k":
+		m.moveSelection(-1)
+	case "down", "j":
+		m.moveSelection(1)
+	case "pgup":
+		m.moveSelection(-m.viewport.Height)
+	case "pgdown":
+		m.moveSelection(m.viewport.Height)
+	case "enter":
+		plain := plainLines(m.rendered)
+		text := normalizeText(strings.Join(plain[from:to+1], " "))
+		note := ""
+		if from == to {
+			// a single line of a highlight edits its note
+			for i, r := range findHighlights(plain, m.highlights) {
+				if from >= r.start && from <= r.end {
+					text, note = m.highlights[i].Text, m.highlights[i].Note
+					break
+				}
+			}
+		}
+		if text != "" {
+			return m, m.noteEditor.Open(text, note, m.width/2)
+		}
+	case "x":
+		ids := make([]int64, 0)
+		for i, r := range findHighlights(plainLines(m.rendered), m.highlights) {
+			if m.selection.cursor >= r.start && m.selection.cursor <= r.end {
+				ids = append(ids, m.highlights[i].Id)
+			}
+		}
+		if len(ids) > 0 {
+			return m, deleteHighlightsCmd(m.item.Id, ids)
+		}
+	}
+	return m, nil
+}
+
+func (m *Model) startSelection() {
+	_, offsets := articleLines(*m)
+	header := strings.Count(getHeader(*m), "\n")
+	cursor := len(offsets) - 1
+	for i, offset := range offsets {
+		if header+offset >= m.viewport.YOffset {
+			cursor = i
+			break
+		}
+	}
+	m.selection = selection{active: true, anchor: cursor, cursor: cursor}
+	m.viewport.SetContent(getViewportContent(*m))
+}
+
+// moveSelection moves the end of the selection, scrolling to keep it visible
+func (m *Model) moveSelection(delta int) {
+	_, offsets := articleLines(*m)
+	m.selection.cursor = max(min(m.selection.cursor+delta, len(offsets)-1), 0)
+	m.viewport.SetContent(getViewportContent(*m))
+	_, offsets = articleLines(*m)
+	line := strings.Count(getHeader(*m), "\n") + offsets[m.selection.cursor]
+	if line < m.viewport.YOffset {
+		m.viewport.SetYOffset(line)
+	} else if line >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(line - m.viewport.Height + 1)
+	}
+}
+
+// ScrollToHighlight scrolls the article to the highlight, if it is still in the article
+func (m *Model) ScrollToHighlight(id int64) {
+	_, offsets := articleLines(*m)
+	for i, r := range findHighlights(plainLines(m.rendered), m.highlights) {
+		if m.highlights[i].Id == id && r.start <= r.end {
+			m.viewport.SetYOffset(strings.Count(getHeader(*m), "\n") + offsets[r.start])
+			return
+		}
+	}
+}
+
+// articleLines returns the lines of the rendered article along with the highlights, their
+// notes, the selection and the matches of the searched text, and the position of each line
+// of the article among them
+func articleLines(m Model) ([]string, []int) {
+	rendered := strings.Split(m.rendered, "\n")
+	plain := plainLines(m.rendered)
+	highlighted := make([]bool, len(rendered))
+	notes := make(map[int][]string)
+	for i, r := range findHighlights(plain, m.highlights) {
+		for line := r.start; line <= r.end; line++ {
+			highlighted[line] = true
+		}
+		if r.start <= r.end && m.highlights[i].Note != "" {
+			notes[r.end] = append(notes[r.end], m.highlights[i].Note)
+		}
+	}
+	lineMatches := make(map[int][]match)
+	for _, match := range m.finder.matches {
+		lineMatches[match.line] = append(lineMatches[match.line], match)
+	}
+	var current *match
+	if len(m.finder.matches) > 0 {
+		current = &m.finder.matches[m.finder.current]
+	}
+	from, to := m.selection.lines()
+	width := m.viewport.Width - m.viewport.Style.GetHorizontalFrameSize()
+	lines := make([]string, 0, len(rendered))
+	offsets := make([]int, len(rendered))
+	for i, line := range rendered {
+		offsets[i] = len(lines)
+		selected := m.selection.active && i >= from && i <= to
+		switch {
+		case len(lineMatches[i]) > 0:
+			style := lipgloss.NewStyle()
+			if selected {
+				style = selectionStyle
+			} else if highlighted[i] {
+				style = highlightStyle
+			}
+			line = styleMatches(plain[i], lineMatches[i], current, style)
+		case selected:
+			line = styleLine(plain[i], selectionStyle)
+		case highlighted[i]:
+			line = styleLine(plain[i], highlightStyle)
+		}
+		lines = append(lines, line)
+		indent := plain[i][:len(plain[i])-len(strings.TrimLeft(plain[i], " "))]
+		for _, note := range notes[i] {
+			wrapped := wordwrap.String("✎ "+note, max(width-len(indent), 10))
+			for _, l := range strings.Split(wrapped, "\n") {
+				lines = append(lines, indent+styles.HintStyle.Render(l))
+			}
+		}
+	}
+	return lines, offsets
+}
+
+// findHighlights returns the lines of the article where each highlight is,
+// the range is empty (start > end) if its text is not in the article
+func findHighlights(plain []string, highlights []models.Highlight) []lineRange {
+	// the article text with normalized spaces, along with the line of each byte
+	var b strings.Builder
+	lineOf := make([]int, 0)
+	for i, line := range plain {
+		for _, word := range strings.Fields(line) {
+			if b.Len() > 0 {
+				b.WriteByte(' ')
+				lineOf = append(lineOf, i)
+			}
+			b.WriteString(word)
+			for range len(word) {
+				lineOf = append(lineOf, i)
+			}
+		}
+	}
+	text := b.String()
+	ranges := make([]lineRange, len(highlights))
+	for i, h := range highlights {
+		anchor := normalizeText(h.Text)
+		at := strings.Index(text, anchor)
+		if anchor == "" || at < 0 {
+			ranges[i] = lineRange{start: 0, end: -1}
+			continue
+		}
+		ranges[i] = lineRange{start: lineOf[at], end: lineOf[at+len(anchor)-1]}
+	}
+	return ranges
+}
+
+type lineRange struct {
+	start int
+	end   int
+}
+
+func styleLine(plain string, style lipgloss.Style) string {
+	text := strings.TrimLeft(plain, " ")
+	indent := plain[:len(plain)-len(text)]
+	text = strings.TrimRight(text, " ")
+	if text == "" {
+		text = " "
+	}
+	return indent + style.Render(text)
+}
+
+func plainLines(rendered string) []string {
+	return strings.Split(ansiEscape.ReplaceAllString(rendered, ""), "\n")
+}
+
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func saveHighlightCmd(saveId, text, note string) tea.Cmd {
+	return func() tea.Msg {
+		if err := db.SaveHighlight(saveId, text, note); err != nil {
+			return highlightsResult{saveId: saveId, err: err}
+		}
+		highlights, err := db.GetHighlights(saveId)
+		return highlightsResult{saveId: saveId, highlights: highlights, err: err}
+	}
+}
+
+func deleteHighlightsCmd(saveId string, ids []int64) tea.Cmd {
+	return func() tea.Msg {
+		for _, id := range ids {
+			if err := db.DeleteHighlight(id); err != nil {
+				return highlightsResult{saveId: saveId, err: err}
+			}
+		}
+		highlights, err := db.GetHighlights(saveId)
+		return highlightsResult{saveId: saveId, highlights: highlights, err: err}
+	}
+}
@@ -1,29 +1,63 @@
 package itemdetail
 
 import (
+	"context"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/thomas-introini/pocket-cli/commands"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/helpkeys"
 	"github.com/thomas-introini/pocket-cli/lib"
 	"github.com/thomas-introini/pocket-cli/models"
 	styles "github.com/thomas-introini/pocket-cli/views"
+	"github.com/thomas-introini/pocket-cli/views/saves"
 )
 
 type getArticleContentResult struct {
-	content string
+	article models.Article
 	err     error
 }
 
+// progressDelay is how long the scroll position must not change before it is stored
+const progressDelay = 500 * time.Millisecond
+
+type storeProgressMsg struct {
+	saveId   string
+	progress int
+	seq      int
+}
+
 type Model struct {
-	width          int
-	height         int
-	item           models.PocketSave
-	viewport       viewport.Model
-	articleContent string
+	ctx      context.Context
+	client   *lib.Client
+	width    int
+	height   int
+	item     models.PocketSave
+	viewport viewport.Model
+	article  models.Article
+	// markdown is the article converted from HTML, rendered is it styled for the viewport width
+	markdown string
+	links    []string
+	rendered string
+	// progress is the scroll position in the article, in percent,
+	// progressSeq counts its changes and pendingProgress holds the last
+	// change of each save not stored yet, so only the last one is stored
+	progress        int
+	progressSeq     int
+	pendingProgress map[string]storeProgressMsg
+	highlights      []models.Highlight
+	selection       selection
+	tagEditor       tagEditor
+	noteEditor      noteEditor
+	finder          finder
+	linkPicker      linkPicker
 }
 
 func (m Model) Init() tea.Cmd {
@@ -35,95 +69,400 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		cmd  tea.Cmd
 		cmds []tea.Cmd
 	)
+	if m.tagEditor.open {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.tagEditor.Close()
+				return m, nil
+			case "enter":
+				m.tagEditor.Close()
+				save, tags := m.item, m.tagEditor.Tags()
+				return m, func() tea.Msg {
+					return EditTagsCmd{Save: save, Tags: tags}
+				}
+			}
+		}
+		m.tagEditor, cmd = m.tagEditor.Update(msg)
+		return m, cmd
+	}
+	if m.noteEditor.open {
+		if msg, ok := msg.(tea.KeyMsg); ok {
+			switch msg.String() {
+			case "esc":
+				m.noteEditor.Close()
+				return m, nil
+			case "enter":
+				m.noteEditor.Close()
+				m.selection.active = false
+				m.viewport.SetContent(getViewportContent(m))
+				return m, saveHighlightCmd(m.item.Id, m.noteEditor.text, strings.TrimSpace(m.noteEditor.input.Value()))
+			}
+			m.noteEditor.input, cmd = m.noteEditor.input.Update(msg)
+			return m, cmd
+		}
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && m.linkPicker.open {
+		return m.updateLinks(msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && m.selection.active {
+		return m.updateSelection(msg)
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && m.finder.typing {
+		return m.updateFind(msg)
+	}
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
+		if m.IsItemSet() {
+			// the selected lines move when the article is wrapped again
+			m.selection.active = false
+			m.viewport.Width, m.viewport.Height = m.width-4, m.height-4
+			m.renderArticle()
+			m.viewport.SetContent(getViewportContent(m))
+			m.restoreProgress()
+		}
+	case knownTagsResult:
+		m.tagEditor, cmd = m.tagEditor.Update(msg)
+		cmds = append(cmds, cmd)
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "g":
-			cmds = append(cmds, getArticleContentCmd(m.item.Url))
-			cmds = append(cmds, commands.SetLabelCmd("Getting article content..."))
+		if !m.IsItemSet() {
+			break
+		}
+		switch {
+		case key.Matches(msg, helpkeys.Get(helpkeys.Open)):
+			cmds = append(cmds, openLinkCmd(m.item.Url))
+		case key.Matches(msg, helpkeys.Get(helpkeys.Archive)):
+			action := lib.ActionArchive
+			if m.item.Status != models.StatusOK {
+				action = lib.ActionReadd
+			}
+			cmds = append(cmds, modifySaveCmd(action, m.item))
+		case key.Matches(msg, helpkeys.Get(helpkeys.Favorite)):
+			action := lib.ActionFavorite
+			if m.item.Favorite {
+				action = lib.ActionUnfavorite
+			}
+			cmds = append(cmds, modifySaveCmd(action, m.item))
+		case key.Matches(msg, helpkeys.Get(helpkeys.Delete)):
+			cmds = append(cmds, modifySaveCmd(lib.ActionDelete, m.item))
+		case key.Matches(msg, helpkeys.Get(helpkeys.EditTags)):
+			cmds = append(cmds, m.tagEditor.Open(m.item.Tags, m.width/2))
+		case key.Matches(msg, helpkeys.Get(helpkeys.GetContent)):
+			cmds = append(cmds, m.GetArticleContent())
+		case key.Matches(msg, helpkeys.Get(helpkeys.Refetch)):
+			cmds = append(cmds, getArticleContentCmd(m, m.item))
+			cmds = append(cmds, commands.SetLabelCmd("Refetching article content..."))
+		case key.Matches(msg, helpkeys.Get(helpkeys.Find)):
+			if m.article.SaveId != "" {
+				return m, m.startFind()
+			}
+		case key.Matches(msg, helpkeys.Get(helpkeys.FindNext)):
+			m.nextMatch(1)
+		case key.Matches(msg, helpkeys.Get(helpkeys.FindPrevious)):
+			m.nextMatch(-1)
+		case key.Matches(msg, helpkeys.Get(helpkeys.Links)):
+			if len(m.links) > 0 {
+				m.openLinks()
+				return m, nil
+			}
+			if m.article.SaveId != "" {
+				cmds = append(cmds, commands.SetLabelCmd("No links"))
+			}
+		case key.Matches(msg, helpkeys.Get(helpkeys.Highlight)):
+			if m.article.SaveId != "" {
+				m.startSelection()
+				return m, nil
+			}
+		}
+	case highlightsResult:
+		if msg.err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(msg.err.Error()))
+		} else if msg.saveId == m.item.Id {
+			m.highlights = msg.highlights
+			m.viewport.SetContent(getViewportContent(m))
+		}
+	case commands.SavesModifiedMsg:
+		if m.IsItemSet() {
+			for _, save := range msg.Saves {
+				if save.Id != m.item.Id {
+					continue
+				}
+				if save.Status == m.item.Status {
+					m.item = save
+					m.viewport.SetContent(getViewportContent(m))
+				} else {
+					m.SetItem(models.PocketSave{})
+				}
+			}
+		}
+	case storeProgressMsg:
+		// positions of the save superseded by a later one are not stored,
+		// even once the save is not shown anymore
+		if pending, ok := m.pendingProgress[msg.saveId]; ok && pending.seq == msg.seq {
+			delete(m.pendingProgress, msg.saveId)
+			cmds = append(cmds, storeProgressCmd(msg.saveId, msg.progress))
 		}
 	case getArticleContentResult:
 		if msg.err != nil {
 			cmds = append(cmds, commands.SetLabelCmd(msg.err.Error()))
 		} else {
-			m.articleContent = msg.content
-			m.viewport.SetContent(getViewportContent(m))
+			if msg.article.SaveId == m.item.Id {
+				m.setArticle(msg.article)
+				m.viewport.SetContent(getViewportContent(m))
+			}
 			cmds = append(cmds, commands.SetLabelCmd(""))
 		}
 	}
 	m.viewport, cmd = m.viewport.Update(msg)
 	cmds = append(cmds, cmd)
+	switch msg.(type) {
+	case tea.KeyMsg, tea.MouseMsg:
+		if progress, ok := m.ReadProgress(); ok && progress != m.progress {
+			// stored once the scrolling stops, not on every line
+			m.progress = progress
+			m.progressSeq++
+			pending := storeProgressMsg{saveId: m.item.Id, progress: progress, seq: m.progressSeq}
+			m.pendingProgress[pending.saveId] = pending
+			cmds = append(cmds, tea.Tick(progressDelay, func(time.Time) tea.Msg {
+				return pending
+			}))
+		}
+	}
 	return m, tea.Batch(cmds...)
 }
 
 func (m *Model) View() string {
+	if m.tagEditor.open {
+		return lipgloss.Place(m.viewport.Width, m.viewport.Height, lipgloss.Center, lipgloss.Center, m.tagEditor.View())
+	}
+	if m.noteEditor.open {
+		return lipgloss.Place(m.viewport.Width, m.viewport.Height, lipgloss.Center, lipgloss.Center, m.noteEditor.View())
+	}
+	if m.linkPicker.open {
+		return lipgloss.Place(m.viewport.Width, m.viewport.Height, lipgloss.Center, lipgloss.Center, m.linksView())
+	}
 	return m.viewport.View()
 }
 
+func (m Model) IsEditing() bool {
+	return m.tagEditor.open || m.noteEditor.open || m.selection.active || m.finder.typing || m.linkPicker.open
+}
+
+// IsSelecting tells whether lines of the article are being selected to be highlighted
+func (m Model) IsSelecting() bool {
+	return m.selection.active
+}
+
 func (m Model) GetItem() models.PocketSave {
 	return m.item
 }
 
 func (m *Model) SetItem(item models.PocketSave) {
-	m.item = item
 	m.viewport = viewport.New(m.width-4, m.height-4)
 	m.viewport.Style = m.viewport.Style.MarginLeft(3)
 	m.viewport.YOffset = 0
+	m.selection.active = false
+	m.linkPicker.open = false
+	m.finder.typing = false
+	m.finder.input.SetValue("")
+	if item.Id != m.item.Id || item.Url != m.item.Url {
+		// a missing article is not an error, it is downloaded on request
+		article, _ := db.GetArticle(item.Id, item.Url)
+		m.setArticle(article)
+		m.progress = 0
+		if pending, ok := m.pendingProgress[item.Id]; ok {
+			m.progress = pending.progress
+		} else if article.SaveId != "" {
+			m.progress, _ = db.GetReadProgress(item.Id)
+		}
+		m.highlights, _ = db.GetHighlights(item.Id)
+	} else {
+		m.renderArticle()
+	}
+	m.item = item
 	if m.IsItemSet() {
 		m.viewport.SetContent(getViewportContent(*m))
+		m.restoreProgress()
 	} else {
 		m.viewport.SetContent("")
 	}
 }
 
+// ReadProgress returns the scroll position in the downloaded article, in percent,
+// ok is false if no article is shown or if it fits in the screen
+func (m Model) ReadProgress() (progress int, ok bool) {
+	if !m.IsItemSet() || m.article.SaveId == "" || m.viewport.TotalLineCount() <= m.viewport.Height {
+		return 0, false
+	}
+	return int(math.Round(m.viewport.ScrollPercent() * 100)), true
+}
+
+func (m *Model) restoreProgress() {
+	scrollable := max(m.viewport.TotalLineCount()-m.viewport.Height, 0)
+	m.viewport.SetYOffset(int(math.Round(float64(m.progress) / 100 * float64(scrollable))))
+}
+
+func (m *Model) setArticle(article models.Article) {
+	m.article = article
+	m.selection.active = false
+	m.markdown, m.links = "", []string{}
+	if article.SaveId != "" {
+		var err error
+		if m.markdown, m.links, err = articleMarkdown(article); err != nil {
+			m.markdown, m.links = article.Text, []string{}
+		}
+	}
+	m.renderArticle()
+}
+
+// renderArticle wraps the article to the width of the viewport, without its margin
+func (m *Model) renderArticle() {
+	m.rendered = m.markdown
+	width := m.viewport.Width - m.viewport.Style.GetHorizontalFrameSize()
+	if m.markdown == "" || width <= 0 {
+		return
+	}
+	if rendered, err := renderMarkdown(m.markdown, width); err == nil {
+		m.rendered = rendered
+	}
+	m.findMatches()
+}
+
+// GetArticleContent downloads the article of the save, unless it is already downloaded
+func (m Model) GetArticleContent() tea.Cmd {
+	if !m.IsItemSet() || m.article.SaveId != "" {
+		return nil
+	}
+	return tea.Batch(getArticleContentCmd(m, m.item), commands.SetLabelCmd("Getting article content..."))
+}
+
 func (m Model) IsItemSet() bool {
-	return m.item != models.PocketSave{}
+	return m.item.Id != ""
 }
 
-func New() Model {
-	return Model{}
+func New(ctx context.Context, client *lib.Client) Model {
+	return Model{
+		ctx:             ctx,
+		client:          client,
+		tagEditor:       newTagEditor(),
+		noteEditor:      newNoteEditor(),
+		finder:          newFinder(),
+		pendingProgress: make(map[string]storeProgressMsg),
+	}
 }
 
 func getViewportContent(m Model) string {
+	content := getHeader(m)
+	if m.article.SaveId == "" {
+		if m.item.SaveDescription == "" {
+			content += "No description available"
+		} else {
+			content += m.item.SaveDescription
+		}
+	} else {
+		lines, _ := articleLines(m)
+		content += strings.Join(lines, "\n")
+	}
+	return content
+}
+
+// getHeader returns the details of the save shown above its content
+func getHeader(m Model) string {
 	item := m.item
 	addedOn := time.Unix(int64(item.UpdatedOn), 0)
 	content := ""
 	content += styles.TitleBoldRedStyle.Render("Title:") + " " + item.SaveTitle + "\n"
 	content += styles.TitleBoldRedStyle.Render("URL:") + " " + item.Url + "\n"
-	if item.Tags != "" {
-		tags := strings.Split(item.Tags, ",")
-		for i, tag := range tags {
+	if item.ResolvedUrl != "" && item.ResolvedUrl != item.Url {
+		content += styles.TitleBoldRedStyle.Render("Resolved URL:") + " " + item.ResolvedUrl + "\n"
+	}
+	if item.Domain != "" {
+		content += styles.TitleBoldRedStyle.Render("Site:") + " " + item.Domain + "\n"
+	}
+	if len(item.Authors) > 0 {
+		content += styles.TitleBoldRedStyle.Render("Authors:") + " " + strings.Join(item.Authors, ", ") + "\n"
+	}
+	if item.Lang != "" {
+		content += styles.TitleBoldRedStyle.Render("Language:") + " " + item.Lang + "\n"
+	}
+	if len(item.Tags) > 0 {
+		tags := make([]string, len(item.Tags))
+		for i, tag := range item.Tags {
 			tags[i] = "#" + tag
 		}
 		tagStr := styles.TitleRedStyle.Render(strings.Join(tags, " "))
 		content += styles.TitleBoldRedStyle.Render("Tags:") + " " + tagStr + "\n"
 	}
+	if item.Favorite {
+		content += styles.TitleBoldRedStyle.Render("Favorite:") + " " + styles.TitleRedStyle.Render("★") + "\n"
+	}
 	if item.TimeToRead > 0 {
 		content += styles.TitleBoldRedStyle.Render("Reading time:") + " ~" + strconv.Itoa(int(item.TimeToRead)) + " mins\n"
 	}
+	if item.WordCount > 0 {
+		content += styles.TitleBoldRedStyle.Render("Words:") + " " + strconv.Itoa(int(item.WordCount)) + "\n"
+	}
+	if item.ListenDuration > 0 {
+		content += styles.TitleBoldRedStyle.Render("Listening time:") + " ~" + strconv.Itoa(int(item.ListenDuration+59)/60) + " mins\n"
+	}
+	if kind := contentKind(item); kind != "" {
+		content += styles.TitleBoldRedStyle.Render("Content:") + " " + kind + "\n"
+	}
+	if item.TopImageUrl != "" {
+		content += styles.TitleBoldRedStyle.Render("Image:") + " " + item.TopImageUrl + "\n"
+	}
+	if len(item.Images) > 1 {
+		content += styles.TitleBoldRedStyle.Render("Images:") + " " + strconv.Itoa(len(item.Images)) + "\n"
+	}
 	content += styles.TitleBoldRedStyle.Render("Added on:") + " " + addedOn.Format("Mon Jan 2 2006 15:04") + "\n"
-	content += "\n"
-	if m.articleContent == "" {
-		if item.SaveDescription == "" {
-			content += "No description available"
-		} else {
-			content += item.SaveDescription
+	if m.article.SaveId != "" {
+		if m.article.Byline != "" {
+			content += styles.TitleBoldRedStyle.Render("Byline:") + " " + m.article.Byline + "\n"
 		}
-	} else {
-		content += m.articleContent
+		fetchedOn := time.Unix(m.article.FetchedOn, 0)
+		content += styles.TitleBoldRedStyle.Render("Downloaded on:") + " " + fetchedOn.Format("Mon Jan 2 2006 15:04") + "\n"
 	}
+	content += "\n"
 	return content
 }
 
-func getArticleContentCmd(url string) tea.Cmd {
+func contentKind(item models.PocketSave) string {
+	kinds := make([]string, 0)
+	if item.IsArticle {
+		kinds = append(kinds, "article")
+	}
+	if item.HasVideo {
+		kinds = append(kinds, "video")
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// modifySaveCmd applies the action to the save being read, not to the one selected in the list
+func modifySaveCmd(action string, save models.PocketSave) tea.Cmd {
+	return func() tea.Msg {
+		return saves.ModifySavesCmd{Action: action, Saves: []models.PocketSave{save}}
+	}
+}
+
+func storeProgressCmd(saveId string, progress int) tea.Cmd {
 	return func() tea.Msg {
-		content, err := lib.GetArticleContent(url)
+		if err := db.SetReadProgress(saveId, progress); err != nil {
+			return commands.SetLabelMsg{Show: true, Message: err.Error()}
+		}
+		return commands.ReadProgressMsg{SaveId: saveId, Progress: progress}
+	}
+}
+
+func getArticleContentCmd(m Model, save models.PocketSave) tea.Cmd {
+	return func() tea.Msg {
+		article, err := m.client.GetArticle(m.ctx, save.Id, save.Url)
 		if err != nil {
 			return getArticleContentResult{err: err}
 		}
-
-		return getArticleContentResult{content: content}
+		if err = db.SaveArticle(article); err != nil {
+			return getArticleContentResult{err: err}
+		}
+		return getArticleContentResult{article: article}
 	}
 }
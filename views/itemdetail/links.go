@@ -0,0 +1,106 @@
+package itemdetail
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+	"github.com/thomas-introini/pocket-cli/commands"
+	"github.com/thomas-introini/pocket-cli/utils"
+	styles "github.com/thomas-introini/pocket-cli/views"
+	"github.com/thomas-introini/pocket-cli/views/saves"
+)
+
+const linkPickerHeight = 10
+
+var linkReference = regexp.MustCompile(`\[(\d+)\]`)
+
+// ReadLinkCmd asks to show the page of the link in the reader
+type ReadLinkCmd struct {
+	Url string
+}
+
+// linkPicker lists the links of the article, numbered as in the rendered text
+type linkPicker struct {
+	open   bool
+	cursor int
+}
+
+// updateLinks handles the keys while the links are listed
+func (m Model) updateLinks(msg tea.KeyMsg) (Model, tea.Cmd) {
+	last := len(m.links) - 1
+	url := m.links[m.linkPicker.cursor]
+	switch msg.String() {
+	case "esc", "q":
+		m.linkPicker.open = false
+	case "up", "k":
+		m.linkPicker.cursor = max(m.linkPicker.cursor-1, 0)
+	case "down", "j":
+		m.linkPicker.cursor = min(m.linkPicker.cursor+1, last)
+	case "pgup":
+		m.linkPicker.cursor = max(m.linkPicker.cursor-linkPickerHeight, 0)
+	case "pgdown":
+		m.linkPicker.cursor = min(m.linkPicker.cursor+linkPickerHeight, last)
+	case "enter", "o":
+		m.linkPicker.open = false
+		return m, openLinkCmd(url)
+	case "a":
+		m.linkPicker.open = false
+		return m, func() tea.Msg {
+			return saves.AddSaveCmd{Url: url}
+		}
+	case "r":
+		m.linkPicker.open = false
+		return m, func() tea.Msg {
+			return ReadLinkCmd{Url: url}
+		}
+	}
+	return m, nil
+}
+
+// openLinks lists the links, starting from the first one referenced on the screen
+func (m *Model) openLinks() {
+	m.linkPicker = linkPicker{open: true}
+	lines, _ := articleLines(*m)
+	top := m.viewport.YOffset - strings.Count(getHeader(*m), "\n")
+	for i := max(top, 0); i < min(top+m.viewport.Height, len(lines)); i++ {
+		reference := linkReference.FindStringSubmatch(ansiEscape.ReplaceAllString(lines[i], ""))
+		if reference == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(reference[1]); err == nil && n >= 1 && n <= len(m.links) {
+			m.linkPicker.cursor = n - 1
+			return
+		}
+	}
+}
+
+func (m Model) linksView() string {
+	width := m.width / 2
+	first := max(min(m.linkPicker.cursor-linkPickerHeight/2, len(m.links)-linkPickerHeight), 0)
+	digits := len(strconv.Itoa(len(m.links)))
+	view := styles.TitleBoldRedStyle.Render("Links") + " "
+	view += styles.HintStyle.Render(fmt.Sprintf("%d/%d", m.linkPicker.cursor+1, len(m.links))) + "\n\n"
+	for i := first; i < min(first+linkPickerHeight, len(m.links)); i++ {
+		line := truncate.StringWithTail(fmt.Sprintf("%*d. %s", digits, i+1, m.links[i]), uint(width), "…")
+		if i == m.linkPicker.cursor {
+			view += styles.TitleBoldRedStyle.Render("> "+line) + "\n"
+		} else {
+			view += "  " + line + "\n"
+		}
+	}
+	view += "\n" + styles.HintStyle.Render("enter open in browser • a save to Pocket • r read • esc close")
+	return styles.ModalStyle.Render(view)
+}
+
+func openLinkCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		if err := utils.OpenInBrowser(url); err != nil {
+			return commands.SetLabelMsg{Show: true, Message: err.Error()}
+		}
+		return nil
+	}
+}
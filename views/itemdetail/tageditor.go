@@ -0,0 +1,130 @@
+package itemdetail
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/models"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+type EditTagsCmd struct {
+	Save models.PocketSave
+	Tags []string
+}
+
+type knownTagsResult struct {
+	tags []string
+	err  error
+}
+
+type tagEditor struct {
+	open      bool
+	input     textinput.Model
+	knownTags []string
+}
+
+func newTagEditor() tagEditor {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.PromptStyle = styles.TitleRedStyle
+	input.Placeholder = "tag1, tag2"
+	input.ShowSuggestions = true
+	input.CharLimit = 256
+	return tagEditor{input: input}
+}
+
+func (e *tagEditor) Open(tags []string, width int) tea.Cmd {
+	e.open = true
+	e.input.Width = width
+	e.input.SetValue("")
+	if len(tags) > 0 {
+		e.input.SetValue(strings.Join(tags, ", ") + ", ")
+	}
+	e.input.CursorEnd()
+	return tea.Batch(e.input.Focus(), getKnownTagsCmd())
+}
+
+func (e *tagEditor) Close() {
+	e.open = false
+	e.input.Blur()
+}
+
+func (e tagEditor) Update(msg tea.Msg) (tagEditor, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case knownTagsResult:
+		if msg.err == nil {
+			e.knownTags = msg.tags
+		}
+	case tea.KeyMsg:
+		e.input, cmd = e.input.Update(msg)
+	}
+	e.input.SetSuggestions(tagSuggestions(e.input.Value(), e.knownTags))
+	return e, cmd
+}
+
+func (e tagEditor) View() string {
+	view := styles.TitleBoldRedStyle.Render("Edit tags") + "\n\n"
+	view += e.input.View() + "\n\n"
+	value := e.input.Value()
+	prefix := currentTagPrefix(value)
+	matches := make([]string, 0)
+	if len(value) > len(prefix) {
+		for _, suggestion := range e.input.AvailableSuggestions() {
+			if strings.HasPrefix(strings.ToLower(suggestion), strings.ToLower(value)) {
+				matches = append(matches, "#"+suggestion[len(prefix):])
+			}
+		}
+	}
+	if len(matches) > 0 {
+		view += styles.HintStyle.Render(strings.Join(matches, " ")) + "\n"
+	}
+	view += styles.HintStyle.Render("tab complete • enter save • esc cancel")
+	return styles.ModalStyle.Render(view)
+}
+
+func (e tagEditor) Tags() []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(e.input.Value(), ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func currentTagPrefix(value string) string {
+	start := strings.LastIndex(value, ",") + 1
+	for start < len(value) && value[start] == ' ' {
+		start++
+	}
+	return value[:start]
+}
+
+func tagSuggestions(value string, knownTags []string) []string {
+	prefix := currentTagPrefix(value)
+	used := make(map[string]bool)
+	for _, tag := range strings.Split(prefix, ",") {
+		used[strings.TrimSpace(tag)] = true
+	}
+	suggestions := make([]string, 0, len(knownTags))
+	for _, tag := range knownTags {
+		if !used[tag] {
+			suggestions = append(suggestions, prefix+tag)
+		}
+	}
+	return suggestions
+}
+
+func getKnownTagsCmd() tea.Cmd {
+	return func() tea.Msg {
+		tags, err := db.GetAllTags()
+		return knownTagsResult{tags: tags, err: err}
+	}
+}
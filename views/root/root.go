@@ -2,44 +2,117 @@ package root
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
-	"sort"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/thomas-introini/pocket-cli/commands"
+	"github.com/thomas-introini/pocket-cli/config"
 	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/downloader"
 	"github.com/thomas-introini/pocket-cli/globals"
 	"github.com/thomas-introini/pocket-cli/helpkeys"
 	"github.com/thomas-introini/pocket-cli/lib"
 	"github.com/thomas-introini/pocket-cli/models"
+	"github.com/thomas-introini/pocket-cli/outbox"
+	"github.com/thomas-introini/pocket-cli/syncer"
+	"github.com/thomas-introini/pocket-cli/utils"
 	styles "github.com/thomas-introini/pocket-cli/views"
 	"github.com/thomas-introini/pocket-cli/views/auth"
+	"github.com/thomas-introini/pocket-cli/views/highlights"
 	"github.com/thomas-introini/pocket-cli/views/itemdetail"
 	"github.com/thomas-introini/pocket-cli/views/saves"
+	"github.com/thomas-introini/pocket-cli/views/tags"
 	titlebar "github.com/thomas-introini/pocket-cli/views/toolbar"
 )
 
 type View int
 
+const highlightsExportFile = "tasca-highlights.md"
+
 const (
 	Auth View = iota
 	SaveList
 	ItemDetail
+	TagManager
+	HighlightList
 )
 
 type getSavesResult struct {
-	saves []models.PocketSave
-	count int
+	filter db.SavesFilter
+	saves  []models.PocketSave
+	count  int
+	synced bool
+	err    error
+}
+
+type syncTickMsg struct {
+}
+
+type syncProgressMsg struct {
+	done  int
+	total int
+}
+
+type syncResult struct {
+	result syncer.Result
+	err    error
+}
+
+type downloadProgressMsg struct {
+	done  int
+	total int
+}
+
+type downloadResult struct {
+	result downloader.Result
+	err    error
+}
+
+type searchResult struct {
+	query   string
+	results []db.SearchResult
+	err     error
+}
+
+type getTagsResult struct {
+	tags []models.PocketTag
+	err  error
+}
+
+type getHighlightsResult struct {
+	highlights []models.SaveHighlights
+	err        error
+}
+
+type readLinkResult struct {
+	save  models.PocketSave
+	added bool
 	err   error
 }
 
+type tagsModifiedResult struct {
+	err error
+}
+
+type replayOutboxMsg struct {
+	scheduled bool
+}
+
+type outboxReplayedResult struct {
+	result outbox.ReplayResult
+}
+
 type authResult struct {
 	authFailure string
 	openBrowser bool
@@ -72,8 +145,12 @@ type window struct {
 }
 
 type model struct {
-	window         window
-	user           models.PocketUser
+	window window
+	user   models.PocketUser
+	client *lib.Client
+	// ctx is canceled on quit to stop the requests in flight
+	ctx            context.Context
+	cancel         context.CancelFunc
 	authenticating bool
 	currentView    View
 	titleBar       titlebar.Model
@@ -81,8 +158,16 @@ type model struct {
 	saves          saves.Model
 	help           help.Model
 	itemdetail     itemdetail.Model
-	errorMessage   string
+	tags           tags.Model
+	highlights     highlights.Model
+	notice         string
 	keys           keyMap
+	replaying      bool
+	replayPlanned  bool
+	syncing        bool
+	// resync is true when a full sync must follow the one running
+	resync      bool
+	downloading bool
 }
 
 func (m model) IsAuthenticated() bool {
@@ -97,6 +182,8 @@ func (m model) Init() tea.Cmd {
 		m.auth.Init(),
 		m.saves.Init(),
 		loadSaves(m),
+		func() tea.Msg { return replayOutboxMsg{} },
+		scheduleSync(),
 	)
 }
 
@@ -105,22 +192,44 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmd  tea.Cmd
 		cmds []tea.Cmd
 	)
+	// the keys of the reader are not meant for the saves list hidden behind it
+	_, isKey := msg.(tea.KeyMsg)
+	reading := m.itemdetail.IsItemSet()
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.window.width, m.window.height = msg.Width, msg.Height
 		m.help.Width = m.window.width - 5
 	case tea.KeyMsg:
+		if m.itemdetail.IsEditing() {
+			m.itemdetail, cmd = m.itemdetail.Update(msg)
+			return m, cmd
+		}
+		if m.saves.IsEditing() {
+			m.saves, cmd = m.saves.Update(msg)
+			return m, cmd
+		}
+		if m.currentView == TagManager && msg.String() != "ctrl+c" {
+			m.tags, cmd = m.tags.Update(msg)
+			return m, cmd
+		}
+		if m.currentView == HighlightList && msg.String() != "ctrl+c" {
+			m.highlights, cmd = m.highlights.Update(msg)
+			return m, cmd
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
+			m.cancel()
 			return m, tea.Quit
 		case "enter":
 			if !m.IsAuthenticated() {
 				m.authenticating = true
-				cmds = append(cmds, startAuthentication())
+				cmds = append(cmds, startAuthentication(m))
 			}
 		case "esc":
-			m.itemdetail.SetItem(models.PocketSave{})
+			if !m.itemdetail.ClearFind() {
+				m.itemdetail.SetItem(models.PocketSave{})
+			}
 		}
 	case commands.SetLabelMsg:
 		if msg.Show {
@@ -129,8 +238,200 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.titleBar.ClearMessage()
 		}
 	case saves.RefreshSavesCmd:
-		cmds = append(cmds, refreshSaves(m))
-		m.titleBar.ShowMessage("Refreshing saves...")
+		if !m.syncing && m.IsAuthenticated() {
+			m.syncing = true
+			cmds = append(cmds, syncSaves(m))
+			m.titleBar.ShowMessage("Refreshing saves...")
+		}
+	case syncTickMsg:
+		cmds = append(cmds, scheduleSync())
+		if !m.syncing && m.IsAuthenticated() {
+			m.syncing = true
+			cmds = append(cmds, syncSaves(m))
+		}
+	case syncProgressMsg:
+		m.saves.SetProgress(msg.done, msg.total)
+		if m.syncing && msg.total > lib.PageSize {
+			m.titleBar.ShowMessage("Syncing saves " + saves.ProgressLabel(msg.done, msg.total))
+		}
+	case syncResult:
+		m.syncing = false
+		if msg.err != nil {
+			cmds = append(cmds, m.handleError(msg.err))
+		} else {
+			m.titleBar.ClearMessage()
+			m.titleBar.SetLastSync(time.Now(), msg.result.String())
+			cmds = append(cmds, m.saves.MergeSaves(msg.result.Changes()))
+			for _, removed := range msg.result.Removed {
+				if removed.Id == m.itemdetail.GetItem().Id {
+					m.itemdetail.SetItem(models.PocketSave{})
+				}
+			}
+		}
+		if m.resync {
+			m.resync = false
+			m.syncing = true
+			cmds = append(cmds, resyncSaves(m))
+		}
+	case saves.DownloadArticlesCmd:
+		if !m.downloading {
+			m.downloading = true
+			cmds = append(cmds, downloadArticles(m, msg.Filter))
+			m.titleBar.ShowMessage("Downloading articles...")
+		}
+	case downloadProgressMsg:
+		if m.downloading {
+			m.titleBar.ShowMessage("Downloading articles " + saves.ProgressLabel(msg.done, msg.total))
+		}
+	case downloadResult:
+		m.downloading = false
+		if msg.err != nil {
+			cmds = append(cmds, m.handleError(msg.err))
+		} else {
+			label := "Articles " + msg.result.String()
+			if len(msg.result.Failed) > 0 || msg.result.Skipped > 0 {
+				label += ", run tasca download for details"
+			}
+			m.titleBar.ShowMessage(label)
+		}
+	case saves.ModifySavesCmd:
+		cmds = append(cmds, modifySaves(m, msg.Action, msg.Saves))
+		m.titleBar.ShowMessage(modifyingLabel(msg.Action))
+	case saves.AddSaveCmd:
+		cmds = append(cmds, addSave(m, msg.Url, msg.Title, msg.Tags))
+		m.titleBar.ShowMessage("Adding save...")
+	case itemdetail.ReadLinkCmd:
+		cmds = append(cmds, readLink(m, msg.Url))
+	case readLinkResult:
+		if msg.err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(msg.err.Error()))
+		} else {
+			if msg.added {
+				save := msg.save
+				cmds = append(cmds, func() tea.Msg {
+					return commands.SavesModifiedMsg{Action: lib.ActionAdd, Saves: []models.PocketSave{save}}
+				})
+			}
+			// saves added are selected once the list shows them
+			m.saves.SelectSave(msg.save.Id)
+			m.itemdetail.SetItem(msg.save)
+			cmds = append(cmds, m.itemdetail.GetArticleContent())
+		}
+	case saves.SearchSavesCmd:
+		cmds = append(cmds, searchSaves(msg.Query))
+	case saves.ReloadSavesCmd:
+		cmds = append(cmds, loadSaves(m))
+	case saves.SwitchViewCmd:
+		m.titleBar.SetTabs(m.saves.Tabs(), m.saves.ActiveTab())
+		m.itemdetail.SetItem(models.PocketSave{})
+		cmds = append(cmds, loadSaves(m))
+	case searchResult:
+		if msg.err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(msg.err.Error()))
+		} else {
+			m.saves.SetSearchResults(msg.query, msg.results)
+		}
+	case saves.ManageTagsCmd:
+		m.currentView = TagManager
+		m.itemdetail.SetItem(models.PocketSave{})
+		cmds = append(cmds, loadTags())
+	case tags.CloseCmd:
+		m.currentView = SaveList
+	case saves.ShowHighlightsCmd:
+		m.currentView = HighlightList
+		m.itemdetail.SetItem(models.PocketSave{})
+		cmds = append(cmds, loadHighlights())
+	case highlights.CloseCmd:
+		m.currentView = SaveList
+	case highlights.OpenSaveCmd:
+		save, err := db.GetPocketSave(msg.Save.Id)
+		if err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(err.Error()))
+		} else {
+			m.currentView = SaveList
+			m.saves.SelectSave(save.Id)
+			m.itemdetail.SetItem(save)
+			m.itemdetail.ScrollToHighlight(msg.Highlight.Id)
+		}
+	case highlights.DeleteHighlightCmd:
+		cmds = append(cmds, deleteHighlight(msg.Highlight))
+	case highlights.ExportCmd:
+		cmds = append(cmds, exportHighlights())
+	case getHighlightsResult:
+		if msg.err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(msg.err.Error()))
+		} else {
+			m.highlights.SetHighlights(msg.highlights)
+		}
+	case tags.RenameTagCmd:
+		cmds = append(cmds, renameTag(m, msg.OldTag, msg.NewTag))
+		m.titleBar.ShowMessage("Renaming #" + msg.OldTag + "...")
+	case tags.DeleteTagCmd:
+		cmds = append(cmds, deleteTag(m, msg.Tag))
+		m.titleBar.ShowMessage("Deleting #" + msg.Tag + "...")
+	case getTagsResult:
+		if msg.err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(msg.err.Error()))
+		} else {
+			m.tags.SetTags(msg.tags)
+		}
+	case tagsModifiedResult:
+		if msg.err != nil {
+			cmds = append(cmds, m.handleError(msg.err))
+		} else {
+			m.titleBar.ClearMessage()
+			cmds = append(cmds, loadTags(), loadSaves(m), func() tea.Msg { return replayOutboxMsg{} })
+		}
+	case itemdetail.EditTagsCmd:
+		cmds = append(cmds, editTags(m, msg.Save, msg.Tags))
+		m.titleBar.ShowMessage("Updating tags...")
+	case commands.SavesModifiedMsg:
+		if msg.Err != nil {
+			cmds = append(cmds, commands.SetLabelCmd(msg.Err.Error()))
+		} else {
+			m.titleBar.ClearMessage()
+			cmds = append(cmds, func() tea.Msg { return replayOutboxMsg{} })
+		}
+	case replayOutboxMsg:
+		if msg.scheduled {
+			m.replayPlanned = false
+		}
+		if !m.replaying && m.IsAuthenticated() {
+			m.replaying = true
+			cmds = append(cmds, replayOutbox(m))
+		}
+	case outboxReplayedResult:
+		m.replaying = false
+		m.titleBar.SetStatus(outboxStatus())
+		if errors.Is(msg.result.Err, lib.ErrInvalidToken) {
+			cmds = append(cmds, m.handleError(msg.result.Err))
+		}
+		if len(msg.result.Failed) > 0 {
+			failed := msg.result.Failed[0]
+			cmds = append(cmds, commands.SetLabelCmd(fmt.Sprintf("%s failed: %s", failed.Action, failed.LastError)))
+		}
+		if msg.result.Resync {
+			// the changes rejected by Pocket are undone by fetching all the saves again
+			if m.syncing {
+				m.resync = true
+			} else {
+				m.syncing = true
+				cmds = append(cmds, resyncSaves(m))
+			}
+		} else if len(msg.result.Added) > 0 || len(msg.result.Failed) > 0 {
+			cmds = append(cmds, loadSaves(m))
+		}
+		if added, ok := msg.result.Added[m.itemdetail.GetItem().Id]; ok {
+			if save, err := db.GetPocketSave(added.Id); err == nil {
+				m.itemdetail.SetItem(save)
+			}
+		}
+		if msg.result.Pending > 0 && !m.replayPlanned {
+			m.replayPlanned = true
+			cmds = append(cmds, tea.Tick(msg.result.RetryIn, func(time.Time) tea.Msg {
+				return replayOutboxMsg{scheduled: true}
+			}))
+		}
 	case saves.ViewSaveCmd:
 		if msg.Open || m.itemdetail.IsItemSet() {
 			save := msg.Save
@@ -147,6 +448,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else if msg.accessToken != "" {
 			closeServer <- true
 			m.authenticating = false
+			m.notice = ""
 			m.user = models.PocketUser{
 				AccessToken: msg.accessToken,
 				Username:    msg.username,
@@ -156,19 +458,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.auth.SetLabel("Could not save user...\n")
 				m.authenticating = false
 			}
-			cmds = append(cmds, loadSaves(m))
+			cmds = append(cmds, loadSaves(m), func() tea.Msg { return replayOutboxMsg{} })
 		}
 	case getSavesResult:
 		if msg.err != nil {
-			m.errorMessage = msg.err.Error()
-		} else {
+			m.saves.SetSaves(make([]models.PocketSave, 0))
+			cmds = append(cmds, m.handleError(msg.err))
+		} else if msg.filter == m.saves.Filter() {
 			m.saves.SetSaves(msg.saves)
 		}
-		m.titleBar.ClearMessage()
+		if msg.synced {
+			m.titleBar.SetLastSync(time.Now(), "")
+		}
+		if msg.err == nil {
+			m.titleBar.ClearMessage()
+		}
 	}
 
-	m.saves, cmd = m.saves.Update(msg)
-	cmds = append(cmds, cmd)
+	if !isKey || !reading {
+		m.saves, cmd = m.saves.Update(msg)
+		cmds = append(cmds, cmd)
+	}
 	m.auth, cmd = m.auth.Update(msg)
 	cmds = append(cmds, cmd)
 	/* m.message, cmd = m.message.Update(msg)
@@ -177,6 +487,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	cmds = append(cmds, cmd)
 	m.itemdetail, cmd = m.itemdetail.Update(msg)
 	cmds = append(cmds, cmd)
+	if !isKey {
+		m.tags, cmd = m.tags.Update(msg)
+		cmds = append(cmds, cmd)
+		m.highlights, cmd = m.highlights.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+	if progress, ok := m.itemdetail.ReadProgress(); ok {
+		m.titleBar.SetReadProgress(progress)
+	} else {
+		m.titleBar.SetReadProgress(-1)
+	}
 	return m, tea.Batch(cmds...)
 }
 
@@ -186,13 +507,12 @@ func (m model) View() string {
 	}
 	view := ""
 	helpView := m.help.View(m.keys)
-	if m.errorMessage != "" {
-		view += strings.Repeat("\n", (m.window.height/2)-strings.Count(view, "\n")-2)
-		tmp := styles.TitleRedStyle.Render("! ERROR: " + m.errorMessage + " !\n")
-		view += strings.Repeat(" ", (m.window.width-lipgloss.Width(tmp))/2) + tmp
-	} else if !m.IsAuthenticated() && !m.authenticating {
+	if !m.IsAuthenticated() && !m.authenticating {
 		view += strings.Repeat("\n", (m.window.height/2)-strings.Count(view, "\n")-2)
 		tmp := styles.TitleRedStyle.Render("Welcome to Pocket CLI!") + "\n"
+		if m.notice != "" {
+			tmp = styles.TitleRedStyle.Render(m.notice) + "\n"
+		}
 		view += strings.Repeat(" ", (m.window.width/2)-(lipgloss.Width(tmp)/2)) + tmp
 		tmp = styles.TitleRedStyle.Render("Press '") + styles.TitleBoldRedStyle.Render("Enter") + styles.TitleRedStyle.Render("' to start the authentication") + "\n"
 		view += strings.Repeat(" ", (m.window.width/2)-(lipgloss.Width(tmp)/2)) + tmp
@@ -208,9 +528,20 @@ func (m model) View() string {
 		toolbarMessage := lipgloss.NewStyle().MarginLeft(1).Width(toolbarMaxWidth - 1 - lipgloss.Width(toolbarUser)).Render(msg)
 		view += styles.ToolbarMessage.Width(toolbarMaxWidth).Render(toolbarMessage+toolbarUser) + "\n" */
 		view += m.titleBar.View()
-		if m.itemdetail.IsItemSet() {
+		if m.currentView == TagManager {
+			view += m.tags.View()
+			helpView = ""
+		} else if m.currentView == HighlightList {
+			view += m.highlights.View()
+			helpView = ""
+		} else if m.itemdetail.IsItemSet() {
 			view += m.itemdetail.View()
 			helpView = m.help.View(getItemDetailKeys(m.itemdetail.GetItem()))
+			if m.itemdetail.IsSelecting() {
+				helpView = m.help.View(helpkeys.HighlightKeys{})
+			} else if m.itemdetail.IsFinding() {
+				helpView = m.itemdetail.FindView()
+			}
 		} else {
 			view += m.saves.View()
 			helpView = ""
@@ -221,17 +552,34 @@ func (m model) View() string {
 	return view + strings.Repeat("\n", int(remainingHeight)) + helpView
 }
 
-func New(user models.PocketUser) model {
+func New(client *lib.Client, user models.PocketUser) model {
+	titleBar := titlebar.New(user.Username, "Tasca")
+	savesModel := saves.New(user)
+	titleBar.SetTabs(savesModel.Tabs(), savesModel.ActiveTab())
+	if user.SavesUpdatedOn > 0 {
+		titleBar.SetLastSync(time.Unix(int64(user.SavesUpdatedOn), 0), "")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client.Retry.OnRetry = func(attempt, attempts int, err error) {
+		if p := globals.GetProgram(); p != nil {
+			p.Send(commands.SetLabelCmd(fmt.Sprintf("Retrying (%d/%d)...", attempt, attempts))())
+		}
+	}
 	return model{
 		window:         window{},
 		authenticating: false,
 		user:           user,
+		client:         client,
+		ctx:            ctx,
+		cancel:         cancel,
 		currentView:    SaveList,
-		titleBar:       titlebar.New(user.Username, "Tasca"),
+		titleBar:       titleBar,
 		auth:           auth.New(),
-		saves:          saves.New(user),
+		saves:          savesModel,
 		help:           help.New(),
-		itemdetail:     itemdetail.New(),
+		itemdetail:     itemdetail.New(ctx, client),
+		tags:           tags.New(),
+		highlights:     highlights.New(),
 		keys: keyMap{
 			Quit: key.NewBinding(
 				key.WithKeys("q", "ctrl+c"),
@@ -251,45 +599,44 @@ func getItemDetailKeys(save models.PocketSave) help.KeyMap {
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
 		),
-		Open: key.NewBinding(
-			key.WithKeys("o"),
-			key.WithHelp("o", "open"),
-		),
-		GetContent: key.NewBinding(
-			key.WithKeys("g"),
-			key.WithHelp("g", "get article content"),
-		),
-		Delete: key.NewBinding(
-			key.WithKeys("D"),
-			key.WithHelp("D", "delete"),
-		),
+		Open:       helpkeys.WithHelp(helpkeys.Open, "open"),
+		GetContent: helpkeys.WithHelp(helpkeys.GetContent, "get article content"),
+		Refetch:    helpkeys.WithHelp(helpkeys.Refetch, "refetch article"),
+		Delete:     helpkeys.WithHelp(helpkeys.Delete, "delete"),
+		EditTags:   helpkeys.WithHelp(helpkeys.EditTags, "edit tags"),
+		Highlight:  helpkeys.WithHelp(helpkeys.Highlight, "highlight"),
+		Find:       helpkeys.WithHelp(helpkeys.Find, "find"),
+		Links:      helpkeys.WithHelp(helpkeys.Links, "links"),
+	}
+	if save.Favorite {
+		keys.Favorite = helpkeys.WithHelp(helpkeys.Favorite, "unfavorite")
+	} else {
+		keys.Favorite = helpkeys.WithHelp(helpkeys.Favorite, "favorite")
 	}
 	if save.Status == models.StatusOK {
-		keys.Archive = key.NewBinding(
-			key.WithKeys("A"),
-			key.WithHelp("A", "archive"),
-		)
+		keys.Archive = helpkeys.WithHelp(helpkeys.Archive, "archive")
 	} else {
-		keys.Unarchive = key.NewBinding(
-			key.WithKeys("A"),
-			key.WithHelp("A", "move to saves"),
-		)
+		keys.Unarchive = helpkeys.WithHelp(helpkeys.Archive, "move to saves")
 	}
 	return keys
 }
 
 var closeServer = make(chan bool)
 
-func startAuthentication() tea.Cmd {
+func startAuthentication(m model) tea.Cmd {
 	return func() tea.Msg {
 		p := globals.GetProgram()
-		port := rand.Intn(20) + 7500
+		port := config.GetConfig().AuthCallbackPort
+		if port == 0 {
+			port = rand.Intn(20) + 7500
+		}
 		localAddress := fmt.Sprintf("http://localhost:%d", port)
 		callbackUrl := localAddress + "/callback"
-		srv := &http.Server{Addr: fmt.Sprintf(":%d", port)}
-		code, state, err := lib.GetRequestToken(callbackUrl)
-		http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-			token, username, err := lib.GetAccesToken(state, code)
+		mux := http.NewServeMux()
+		srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+		code, state, err := m.client.GetRequestToken(m.ctx, callbackUrl)
+		mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+			token, username, err := m.client.GetAccessToken(m.ctx, state, code)
 			if err != nil {
 				p.Send(authResult{authFailure: err.Error()})
 			} else {
@@ -309,7 +656,7 @@ func startAuthentication() tea.Cmd {
 				<-closeServer
 				srv.Shutdown(context.Background())
 			}()
-			lib.OpenAuthorizationURL(code, callbackUrl)
+			m.client.OpenAuthorizationURL(code, callbackUrl)
 			return authResult{openBrowser: true}
 		}
 	}
@@ -318,46 +665,268 @@ func startAuthentication() tea.Cmd {
 func loadSaves(m model) tea.Cmd {
 	if m.IsAuthenticated() {
 		m.titleBar.ShowMessage("Refreshing saves...")
+		filter := m.saves.Filter()
 		return func() tea.Msg {
-			saves, err := db.GetPocketSaves()
-			if (err != nil && err == db.NoSavesErr) || len(saves) == 0 {
-				response, err := lib.GetAllPocketSaves(m.user.AccessToken, 0)
-				saves = response.Saves
-				if err != nil {
-					return getSavesResult{err: err}
-				}
-				saves, err := db.InsertSaves(response.Since, saves)
-				if err != nil {
+			found, err := db.HasSaves()
+			if err != nil {
+				return getSavesResult{err: err}
+			}
+			if !found {
+				if _, err = syncer.Sync(m.ctx, m.client, m.user.AccessToken, sendSyncProgress); err != nil {
 					return getSavesResult{err: err}
 				}
-				sort.Sort(models.ByAddedOnDesc(saves))
-				return getSavesResult{count: len(saves), saves: saves}
-			} else if err != nil {
+			}
+			saves, err := db.GetPocketSavesBy(filter)
+			if err != nil {
 				return getSavesResult{err: err}
-			} else {
-				return getSavesResult{count: len(saves), saves: saves}
 			}
+			return getSavesResult{filter: filter, count: len(saves), synced: !found, saves: saves}
+		}
+	} else {
+		return nil
+	}
+}
+
+func syncSaves(m model) tea.Cmd {
+	return func() tea.Msg {
+		result, err := syncer.Sync(m.ctx, m.client, m.user.AccessToken, sendSyncProgress)
+		return syncResult{result: result, err: err}
+	}
+}
+
+// resyncSaves fetches all the saves again, not only the ones changed since the last sync
+func resyncSaves(m model) tea.Cmd {
+	return func() tea.Msg {
+		if err := db.SetSyncCursor(0); err != nil {
+			return syncResult{err: err}
+		}
+		result, err := syncer.Sync(m.ctx, m.client, m.user.AccessToken, sendSyncProgress)
+		return syncResult{result: result, err: err}
+	}
+}
+
+func sendSyncProgress(done, total int) {
+	if p := globals.GetProgram(); p != nil {
+		p.Send(syncProgressMsg{done: done, total: total})
+	}
+}
+
+func downloadArticles(m model, filter db.SavesFilter) tea.Cmd {
+	return func() tea.Msg {
+		list, err := db.GetPocketSavesBy(filter)
+		if err != nil {
+			return downloadResult{err: err}
+		}
+		result, err := downloader.Download(m.ctx, m.client, list, downloader.Options{}, func(done, total int) {
+			if p := globals.GetProgram(); p != nil {
+				p.Send(downloadProgressMsg{done: done, total: total})
+			}
+		})
+		return downloadResult{result: result, err: err}
+	}
+}
+
+func scheduleSync() tea.Cmd {
+	interval := config.GetConfig().SyncInterval
+	if interval <= 0 {
+		return nil
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg {
+		return syncTickMsg{}
+	})
+}
+
+func modifySaves(m model, action string, list []models.PocketSave) tea.Cmd {
+	if m.IsAuthenticated() {
+		return func() tea.Msg {
+			modified, err := outbox.Modify(action, list...)
+			return commands.SavesModifiedMsg{Action: action, Saves: modified, Err: err}
 		}
 	} else {
 		return nil
 	}
 }
 
-func refreshSaves(m model) tea.Cmd {
+func editTags(m model, save models.PocketSave, tags []string) tea.Cmd {
 	if m.IsAuthenticated() {
 		return func() tea.Msg {
-			response, err := lib.GetAllPocketSaves(m.user.AccessToken, float64(m.user.SavesUpdatedOn))
+			save, err := outbox.EditTags(save, tags)
 			if err != nil {
-				return getSavesResult{err: err}
+				return commands.SavesModifiedMsg{Action: lib.ActionTagsReplace, Err: err}
 			}
-			saves, err := db.InsertSaves(response.Since, response.Saves)
+			return commands.SavesModifiedMsg{Action: lib.ActionTagsReplace, Saves: []models.PocketSave{save}}
+		}
+	} else {
+		return nil
+	}
+}
+
+func addSave(m model, url, title string, tags []string) tea.Cmd {
+	if m.IsAuthenticated() {
+		return func() tea.Msg {
+			save, err := outbox.Add(url, title, tags)
 			if err != nil {
-				return getSavesResult{err: err}
+				return commands.SavesModifiedMsg{Action: lib.ActionAdd, Err: err}
 			}
-			sort.Sort(models.ByAddedOnDesc(saves))
-			return getSavesResult{count: len(saves), saves: saves}
+			return commands.SavesModifiedMsg{Action: lib.ActionAdd, Saves: []models.PocketSave{save}}
+		}
+	} else {
+		return nil
+	}
+}
+
+// readLink opens the save of the url, the url is saved first if needed
+func readLink(m model, url string) tea.Cmd {
+	if !m.IsAuthenticated() {
+		return nil
+	}
+	return func() tea.Msg {
+		save, err := db.GetPocketSaveByUrl(url)
+		if err == nil {
+			return readLinkResult{save: save}
+		} else if err != db.NoSaveErr {
+			return readLinkResult{err: err}
+		}
+		save, err = outbox.Add(url, "", nil)
+		return readLinkResult{save: save, added: true, err: err}
+	}
+}
+
+func replayOutbox(m model) tea.Cmd {
+	return func() tea.Msg {
+		return outboxReplayedResult{result: outbox.Replay(m.ctx, m.client, m.user.AccessToken)}
+	}
+}
+
+func outboxStatus() string {
+	pending, failed, err := db.CountPendingActions()
+	if err != nil {
+		return ""
+	}
+	status := make([]string, 0)
+	if pending > 0 {
+		status = append(status, fmt.Sprintf("⇅ %d pending", pending))
+	}
+	if failed > 0 {
+		status = append(status, fmt.Sprintf("⚠ %d failed", failed))
+	}
+	return strings.Join(status, " ")
+}
+
+func searchSaves(query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := db.SearchSaves(query)
+		return searchResult{query: query, results: results, err: err}
+	}
+}
+
+func loadTags() tea.Cmd {
+	return func() tea.Msg {
+		tags, err := db.GetTagCounts()
+		return getTagsResult{tags: tags, err: err}
+	}
+}
+
+func loadHighlights() tea.Cmd {
+	return func() tea.Msg {
+		list, err := db.GetAllHighlights()
+		return getHighlightsResult{highlights: list, err: err}
+	}
+}
+
+func deleteHighlight(highlight models.Highlight) tea.Cmd {
+	return func() tea.Msg {
+		if err := db.DeleteHighlight(highlight.Id); err != nil {
+			return getHighlightsResult{err: err}
+		}
+		list, err := db.GetAllHighlights()
+		return getHighlightsResult{highlights: list, err: err}
+	}
+}
+
+// exportHighlights writes all the highlights as Markdown in the working directory
+func exportHighlights() tea.Cmd {
+	return func() tea.Msg {
+		list, err := db.GetAllHighlights()
+		if err != nil {
+			return commands.SetLabelMsg{Show: true, Message: err.Error()}
+		}
+		err = os.WriteFile(highlightsExportFile, []byte(utils.HighlightsMarkdown(list)), 0o644)
+		if err != nil {
+			return commands.SetLabelMsg{Show: true, Message: "Could not export highlights: " + err.Error()}
+		}
+		return commands.SetLabelMsg{Show: true, Message: "Highlights exported to " + highlightsExportFile}
+	}
+}
+
+func renameTag(m model, oldTag, newTag string) tea.Cmd {
+	if m.IsAuthenticated() {
+		return func() tea.Msg {
+			return tagsModifiedResult{err: outbox.RenameTag(oldTag, newTag)}
+		}
+	} else {
+		return nil
+	}
+}
+
+func deleteTag(m model, tag string) tea.Cmd {
+	if m.IsAuthenticated() {
+		return func() tea.Msg {
+			return tagsModifiedResult{err: outbox.DeleteTag(tag)}
 		}
 	} else {
 		return nil
 	}
 }
+
+// handleError shows a non fatal error in the title bar. When the access token
+// is no longer valid the user is logged out so that enter authenticates again.
+func (m *model) handleError(err error) tea.Cmd {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if errors.Is(err, lib.ErrInvalidToken) {
+		m.user = models.NoUser
+		m.itemdetail.SetItem(models.PocketSave{})
+		m.notice = "Your Pocket session has expired"
+	}
+	return commands.SetLabelCmd(errorLabel(err))
+}
+
+func errorLabel(err error) string {
+	var apiErr *lib.APIError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, lib.ErrInvalidToken):
+		return "Pocket session expired, press enter to log in again"
+	case errors.Is(err, lib.ErrInvalidConsumerKey):
+		return "Invalid consumer key, check consumer_key in " + config.Path()
+	case errors.Is(err, lib.ErrRateLimited) && errors.As(err, &apiErr) && apiErr.RetryAfter > 0:
+		return fmt.Sprintf("Pocket rate limit reached, try again in %d min", int(math.Ceil(apiErr.RetryAfter.Minutes())))
+	case errors.Is(err, lib.ErrRateLimited):
+		return "Pocket rate limit reached, try again later"
+	case errors.Is(err, lib.ErrMaintenance):
+		return "Pocket is down for maintenance, try again later"
+	case errors.As(err, &netErr):
+		return "Could not reach Pocket, check your connection"
+	default:
+		return err.Error()
+	}
+}
+
+func modifyingLabel(action string) string {
+	switch action {
+	case lib.ActionArchive:
+		return "Archiving..."
+	case lib.ActionReadd:
+		return "Moving to saves..."
+	case lib.ActionFavorite:
+		return "Adding to favorites..."
+	case lib.ActionUnfavorite:
+		return "Removing from favorites..."
+	case lib.ActionDelete:
+		return "Deleting..."
+	default:
+		return "Updating..."
+	}
+}
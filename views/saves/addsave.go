@@ -0,0 +1,138 @@
+package saves
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+type AddSaveCmd struct {
+	Url   string
+	Title string
+	Tags  []string
+}
+
+const (
+	urlField = iota
+	titleField
+	tagsField
+)
+
+var fieldLabels = []string{"URL", "Title", "Tags"}
+
+type addForm struct {
+	open   bool
+	inputs []textinput.Model
+	focus  int
+	err    error
+}
+
+func newAddForm() addForm {
+	inputs := make([]textinput.Model, len(fieldLabels))
+	for i := range inputs {
+		input := textinput.New()
+		input.Prompt = "> "
+		input.PromptStyle = styles.TitleRedStyle
+		input.CharLimit = 2048
+		inputs[i] = input
+	}
+	inputs[urlField].Placeholder = "https://"
+	inputs[titleField].Placeholder = "optional"
+	inputs[tagsField].Placeholder = "optional, comma separated"
+	return addForm{inputs: inputs}
+}
+
+func (f *addForm) Open(width int) tea.Cmd {
+	f.open = true
+	f.err = nil
+	f.focus = urlField
+	for i := range f.inputs {
+		f.inputs[i].Width = width
+		f.inputs[i].Reset()
+		f.inputs[i].Blur()
+	}
+	return f.inputs[urlField].Focus()
+}
+
+func (f *addForm) Close() {
+	f.open = false
+	for i := range f.inputs {
+		f.inputs[i].Blur()
+	}
+}
+
+func (f addForm) Update(msg tea.Msg) (addForm, tea.Cmd) {
+	var cmd tea.Cmd
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+		return f, cmd
+	}
+	switch keyMsg.String() {
+	case "esc":
+		f.Close()
+		return f, nil
+	case "tab", "down":
+		return f, f.setFocus((f.focus + 1) % len(f.inputs))
+	case "shift+tab", "up":
+		return f, f.setFocus((f.focus + len(f.inputs) - 1) % len(f.inputs))
+	case "enter":
+		cmd, err := f.submit()
+		if err != nil {
+			f.err = err
+			return f, nil
+		}
+		f.Close()
+		return f, cmd
+	}
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(keyMsg)
+	return f, cmd
+}
+
+func (f addForm) View() string {
+	view := styles.TitleBoldRedStyle.Render("Add a new save") + "\n\n"
+	for i, input := range f.inputs {
+		view += styles.TitleRedStyle.Render(fieldLabels[i]) + "\n" + input.View() + "\n\n"
+	}
+	if f.err != nil {
+		view += styles.TitleRedStyle.Render(f.err.Error()) + "\n"
+	}
+	view += styles.HintStyle.Render("tab next field • enter save • esc cancel")
+	return styles.ModalStyle.Render(view)
+}
+
+func (f *addForm) setFocus(i int) tea.Cmd {
+	f.inputs[f.focus].Blur()
+	f.focus = i
+	return f.inputs[f.focus].Focus()
+}
+
+func (f addForm) submit() (tea.Cmd, error) {
+	rawUrl := strings.TrimSpace(f.inputs[urlField].Value())
+	if rawUrl == "" {
+		return nil, errors.New("URL is required")
+	}
+	if !strings.Contains(rawUrl, "://") {
+		rawUrl = "https://" + rawUrl
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("invalid URL")
+	}
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(f.inputs[tagsField].Value(), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	cmd := AddSaveCmd{
+		Url:   u.String(),
+		Title: strings.TrimSpace(f.inputs[titleField].Value()),
+		Tags:  tags,
+	}
+	return func() tea.Msg { return cmd }, nil
+}
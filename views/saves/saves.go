@@ -1,6 +1,10 @@
 package saves
 
 import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -8,30 +12,15 @@ import (
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/thomas-introini/pocket-cli/commands"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/helpkeys"
+	"github.com/thomas-introini/pocket-cli/lib"
 	"github.com/thomas-introini/pocket-cli/models"
 	"github.com/thomas-introini/pocket-cli/utils"
 	styles "github.com/thomas-introini/pocket-cli/views"
 )
 
-var (
-	titleStyle             = lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4056")).Bold(true)
-	itemStyle              = lipgloss.NewStyle().PaddingLeft(4)
-	selectedItemTitleStyle = lipgloss.NewStyle().
-				Border(lipgloss.NormalBorder(), false, false, false, true).
-				BorderForeground(lipgloss.Color("#ef4056")).
-				Foreground(lipgloss.Color("#ef4056")).
-				Bold(true).
-				Padding(0, 0, 0, 1)
-	selectedItemDescriptionStyle = lipgloss.NewStyle().
-					Border(lipgloss.NormalBorder(), false, false, false, true).
-					BorderForeground(lipgloss.Color("#ef4056")).
-					Foreground(lipgloss.Color("#ef4056")).
-					Padding(0, 0, 0, 1)
-	paginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
-	helpStyle       = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
-	quitTextStyle   = lipgloss.NewStyle().Margin(1, 0, 2, 4)
-)
-
 type RefreshSavesCmd struct {
 }
 
@@ -40,6 +29,44 @@ type ViewSaveCmd struct {
 	Save models.PocketSave
 }
 
+type ManageTagsCmd struct {
+}
+
+type ShowHighlightsCmd struct {
+}
+
+// DownloadArticlesCmd asks to download the articles of the saves shown in the current tab
+type DownloadArticlesCmd struct {
+	Filter db.SavesFilter
+}
+
+type ModifySavesCmd struct {
+	Action string
+	Saves  []models.PocketSave
+}
+
+type SwitchViewCmd struct {
+	Filter db.SavesFilter
+}
+
+type tab struct {
+	title  string
+	filter db.SavesFilter
+}
+
+var tabs = []tab{
+	{title: "Unread", filter: db.UnreadSaves},
+	{title: "Archive", filter: db.ArchivedSaves},
+	{title: "Favorites", filter: db.FavoriteSaves},
+	{title: "All", filter: db.AllSaves},
+}
+
+// cursor is the selection of a tab, restored when switching back to it
+type cursor struct {
+	id    string
+	index int
+}
+
 type window struct {
 	width  int
 	height int
@@ -51,8 +78,14 @@ type Model struct {
 	list         list.Model
 	since        int32
 	loading      bool
+	progress     string
 	spinner      spinner.Model
 	errorMessage string
+	addForm      addForm
+	searchForm   searchForm
+	searchQuery  string
+	tab          int
+	cursors      []cursor
 }
 
 type UpdateSaves struct {
@@ -67,6 +100,22 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	cmds := make([]tea.Cmd, 0)
 	var cmd tea.Cmd
 
+	if m.addForm.open {
+		m.addForm, cmd = m.addForm.Update(msg)
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return m, cmd
+		}
+		cmds = append(cmds, cmd)
+	}
+	if m.searchForm.open {
+		m.searchForm, cmd = m.searchForm.Update(msg)
+		if _, ok := msg.(tea.KeyMsg); ok {
+			return m, cmd
+		}
+		cmds = append(cmds, cmd)
+	}
+	filterState := m.list.FilterState()
+
 	m.spinner, cmd = m.spinner.Update(msg)
 	cmds = append(cmds, cmd)
 	m.list, cmd = m.list.Update(msg)
@@ -75,30 +124,74 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		if m.list.FilterState() != list.Filtering {
-			switch msg.String() {
-			case "R":
+			switch {
+			case key.Matches(msg, helpkeys.Get(helpkeys.Refresh)):
 				cmds = append(cmds, func() tea.Msg {
 					return RefreshSavesCmd{}
 				})
-			case "o":
+			case key.Matches(msg, helpkeys.Get(helpkeys.Add)):
+				cmds = append(cmds, m.addForm.Open(m.window.width/2))
+			case key.Matches(msg, helpkeys.Get(helpkeys.NextView)):
+				cmds = append(cmds, m.switchTab((m.tab+1)%len(tabs)))
+			case key.Matches(msg, helpkeys.Get(helpkeys.PrevView)):
+				cmds = append(cmds, m.switchTab((m.tab+len(tabs)-1)%len(tabs)))
+			case key.Matches(msg, helpkeys.Get(helpkeys.Search)):
+				cmds = append(cmds, m.searchForm.Open(m.searchQuery, m.window.width/2))
+			case msg.String() == "esc":
+				if m.searchQuery != "" && filterState == list.Unfiltered {
+					cmds = append(cmds, func() tea.Msg {
+						return ReloadSavesCmd{}
+					})
+				}
+			case key.Matches(msg, helpkeys.Get(helpkeys.Download)):
+				filter := tabs[m.tab].filter
+				cmds = append(cmds, func() tea.Msg {
+					return DownloadArticlesCmd{Filter: filter}
+				})
+			case key.Matches(msg, helpkeys.Get(helpkeys.Highlights)):
+				cmds = append(cmds, func() tea.Msg {
+					return ShowHighlightsCmd{}
+				})
+			case key.Matches(msg, helpkeys.Get(helpkeys.ManageTags)):
+				cmds = append(cmds, func() tea.Msg {
+					return ManageTagsCmd{}
+				})
+			case key.Matches(msg, helpkeys.Get(helpkeys.Open)):
 				selected, ok := m.list.SelectedItem().(models.PocketSave)
 				if ok {
 					cmds = append(cmds, open(selected.Url))
 				}
-			case "enter":
+			case key.Matches(msg, helpkeys.Get(helpkeys.Archive)):
+				selected, ok := m.list.SelectedItem().(models.PocketSave)
+				if ok {
+					action := lib.ActionArchive
+					if selected.Status != models.StatusOK {
+						action = lib.ActionReadd
+					}
+					cmds = append(cmds, modifySaves(action, selected))
+				}
+			case key.Matches(msg, helpkeys.Get(helpkeys.Favorite)):
+				selected, ok := m.list.SelectedItem().(models.PocketSave)
+				if ok {
+					action := lib.ActionFavorite
+					if selected.Favorite {
+						action = lib.ActionUnfavorite
+					}
+					cmds = append(cmds, modifySaves(action, selected))
+				}
+			case key.Matches(msg, helpkeys.Get(helpkeys.Delete)):
+				selected, ok := m.list.SelectedItem().(models.PocketSave)
+				if ok {
+					cmds = append(cmds, modifySaves(lib.ActionDelete, selected))
+				}
+			case msg.String() == "enter":
 				selected, ok := m.list.SelectedItem().(models.PocketSave)
 				if ok {
 					cmds = append(cmds, func() tea.Msg {
 						return ViewSaveCmd{Open: true, Save: selected}
 					})
 				}
-			case tea.KeyDown.String():
-				fallthrough
-			case tea.KeyUp.String():
-				fallthrough
-			case "j":
-				fallthrough
-			case "k":
+			case key.Matches(msg, m.list.KeyMap.CursorUp, m.list.KeyMap.CursorDown):
 				selected, ok := m.list.SelectedItem().(models.PocketSave)
 				if ok {
 					cmds = append(cmds, func() tea.Msg {
@@ -107,6 +200,16 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				}
 			}
 		}
+	case commands.SavesModifiedMsg:
+		cmds = append(cmds, m.updateSaves(msg.Saves))
+	case commands.ReadProgressMsg:
+		for i, item := range m.list.Items() {
+			if save := item.(models.PocketSave); save.Id == msg.SaveId {
+				save.ReadProgress = msg.Progress
+				cmds = append(cmds, m.list.SetItem(i, save))
+				break
+			}
+		}
 	case openError:
 		m.errorMessage = msg.error.Error()
 	case tea.WindowSizeMsg:
@@ -124,10 +227,24 @@ func (m Model) View() string {
 		tmp := styles.TitleRedStyle.Render("! ERROR" + m.errorMessage + " !")
 		view := strings.Repeat(" ", (m.window.width-lipgloss.Width(tmp))/2) + tmp
 		return view
-	} else if len(m.list.Items()) == 0 {
-		tmp := m.spinner.View() + " " + styles.TitleRedStyle.Render("Fetching your saved items...")
+	} else if m.addForm.open {
+		return lipgloss.Place(m.window.width, m.window.height, lipgloss.Center, lipgloss.Center, m.addForm.View())
+	} else if m.searchForm.open {
+		return lipgloss.Place(m.window.width, m.window.height, lipgloss.Center, lipgloss.Center, m.searchForm.View())
+	} else if m.searchQuery != "" && len(m.list.Items()) == 0 {
+		tmp := styles.TitleRedStyle.Render("No saves found for " + m.searchQuery)
+		return strings.Repeat(" ", (m.window.width-lipgloss.Width(tmp))/2) + tmp
+	} else if m.loading {
+		label := "Fetching your saved items..."
+		if m.progress != "" {
+			label += " " + m.progress
+		}
+		tmp := m.spinner.View() + " " + styles.TitleRedStyle.Render(label)
 		view := strings.Repeat(" ", (m.window.width-lipgloss.Width(tmp))/2) + tmp
 		return view
+	} else if len(m.list.Items()) == 0 {
+		tmp := styles.TitleRedStyle.Render("No saves in " + tabs[m.tab].title)
+		return strings.Repeat(" ", (m.window.width-lipgloss.Width(tmp))/2) + tmp
 	} else {
 		return m.list.View()
 	}
@@ -138,7 +255,179 @@ func (m *Model) SetSaves(saves []models.PocketSave) {
 	for _, s := range saves {
 		items = append(items, s)
 	}
+	if !m.loading && m.searchQuery == "" {
+		m.saveCursor()
+	}
+	m.loading = false
+	m.progress = ""
+	m.searchQuery = ""
+	m.list.SetDelegate(newItemDelegate(nil))
+	m.list.SetShowTitle(false)
+	m.list.SetItems(items)
+	m.restoreCursor()
+}
+
+// SetProgress shows how many saves have been fetched next to the spinner
+func (m *Model) SetProgress(done, total int) {
+	m.progress = ProgressLabel(done, total)
+}
+
+// ProgressLabel formats the fetched saves as "12,000 / 35,000"
+func ProgressLabel(done, total int) string {
+	return formatCount(done) + " / " + formatCount(total)
+}
+
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func (m Model) Tabs() []string {
+	titles := make([]string, len(tabs))
+	for i, t := range tabs {
+		titles[i] = t.title
+	}
+	return titles
+}
+
+func (m Model) ActiveTab() int {
+	return m.tab
+}
+
+func (m Model) Filter() db.SavesFilter {
+	return tabs[m.tab].filter
+}
+
+func (m *Model) switchTab(tab int) tea.Cmd {
+	if m.searchQuery == "" && !m.loading {
+		m.saveCursor()
+	}
+	m.tab = tab
+	m.loading = true
+	m.list.ResetFilter()
+	m.list.SetItems(make([]list.Item, 0))
+	filter := tabs[tab].filter
+	return func() tea.Msg {
+		return SwitchViewCmd{Filter: filter}
+	}
+}
+
+func (m *Model) saveCursor() {
+	selected, ok := m.list.SelectedItem().(models.PocketSave)
+	if ok {
+		m.cursors[m.tab] = cursor{id: selected.Id, index: m.list.Index()}
+	}
+}
+
+func (m *Model) restoreCursor() {
+	c := m.cursors[m.tab]
+	for i, item := range m.list.VisibleItems() {
+		if item.(models.PocketSave).Id == c.id {
+			m.list.Select(i)
+			return
+		}
+	}
+	m.list.Select(min(c.index, max(len(m.list.VisibleItems())-1, 0)))
+}
+
+// SelectSave moves the cursor to the save, if the list shows it
+func (m *Model) SelectSave(id string) {
+	for i, item := range m.list.VisibleItems() {
+		if item.(models.PocketSave).Id == id {
+			m.list.Select(i)
+			return
+		}
+	}
+}
+
+func (m *Model) SetSearchResults(query string, results []db.SearchResult) {
+	items := make([]list.Item, 0, len(results))
+	highlights := make(map[string]db.SearchResult, len(results))
+	for _, r := range results {
+		items = append(items, r.Save)
+		highlights[r.Save.Id] = r
+	}
+	m.searchQuery = query
+	m.list.SetDelegate(newItemDelegate(highlights))
+	m.list.Title = fmt.Sprintf("Search: %s (%d)", query, len(results))
+	m.list.SetShowTitle(true)
+	m.list.ResetFilter()
 	m.list.SetItems(items)
+	m.list.Select(0)
+}
+
+func (m Model) IsEditing() bool {
+	return m.addForm.open || m.searchForm.open
+}
+
+// MergeSaves applies the saves changed since the last sync keeping the selected save and the active filter
+func (m *Model) MergeSaves(saves []models.PocketSave) tea.Cmd {
+	if m.loading {
+		return nil
+	}
+	cmds := make([]tea.Cmd, 0)
+	selected, _ := m.list.SelectedItem().(models.PocketSave)
+	for _, save := range saves {
+		i := slices.IndexFunc(m.list.Items(), func(item list.Item) bool {
+			return item.(models.PocketSave).Id == save.Id
+		})
+		switch {
+		case i >= 0 && m.shows(save):
+			// the reading progress is local, Pocket does not send it
+			save.ReadProgress = m.list.Items()[i].(models.PocketSave).ReadProgress
+			cmds = append(cmds, m.list.SetItem(i, save))
+		case i >= 0:
+			m.list.RemoveItem(i)
+		case m.searchQuery == "" && m.shows(save):
+			// keep the list sorted by the date the saves were added
+			at, _ := slices.BinarySearchFunc(m.list.Items(), save, func(item list.Item, save models.PocketSave) int {
+				return cmp.Compare(save.AddedOn, item.(models.PocketSave).AddedOn)
+			})
+			cmds = append(cmds, m.list.InsertItem(at, save))
+		}
+	}
+	for i, item := range m.list.VisibleItems() {
+		if item.(models.PocketSave).Id == selected.Id {
+			m.list.Select(i)
+			break
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *Model) updateSaves(saves []models.PocketSave) tea.Cmd {
+	var cmd tea.Cmd
+	for _, save := range saves {
+		found := false
+		for i, item := range m.list.Items() {
+			if item.(models.PocketSave).Id != save.Id {
+				continue
+			}
+			found = true
+			if m.shows(save) {
+				save.ReadProgress = item.(models.PocketSave).ReadProgress
+				m.list.SetItem(i, save)
+			} else {
+				m.list.RemoveItem(i)
+			}
+			break
+		}
+		if !found && m.searchQuery == "" && m.shows(save) {
+			cmd = m.list.InsertItem(0, save)
+			m.list.Select(0)
+		}
+	}
+	return cmd
+}
+
+func (m Model) shows(save models.PocketSave) bool {
+	if m.searchQuery != "" {
+		return save.Status != models.StatusDeleted
+	}
+	return m.Filter().Matches(save)
 }
 
 func New(user models.PocketUser) Model {
@@ -146,29 +435,28 @@ func New(user models.PocketUser) Model {
 	s.Spinner = spinner.Line
 	s.Style = styles.TitleRedStyle
 
-	id := list.NewDefaultDelegate()
-	id.Styles.SelectedTitle = selectedItemTitleStyle
-	id.Styles.SelectedDesc = selectedItemDescriptionStyle
-
-	list := list.New(make([]list.Item, 0), id, 10, 10)
+	list := list.New(make([]list.Item, 0), newItemDelegate(nil), 10, 10)
 	list.DisableQuitKeybindings()
 	list.Title = "Saves"
 	list.SetShowTitle(false)
-	list.Styles.Title = titleStyle
+	list.Styles.Title = styles.TitleBoldRedStyle
 	list.AdditionalShortHelpKeys = func() []key.Binding {
 		return []key.Binding{
 			key.NewBinding(
 				key.WithKeys("Enter", "<cr>"),
 				key.WithHelp("Enter", "View"),
 			),
-			key.NewBinding(
-				key.WithKeys("o"),
-				key.WithHelp("o", "Open"),
-			),
-			key.NewBinding(
-				key.WithKeys("R"),
-				key.WithHelp("R", "Refresh saves"),
-			),
+			helpkeys.WithHelp(helpkeys.Open, "Open"),
+			helpkeys.WithHelp(helpkeys.Refresh, "Refresh saves"),
+			helpkeys.WithHelp(helpkeys.Add, "Add"),
+			helpkeys.WithHelp(helpkeys.Archive, "Archive"),
+			helpkeys.WithHelp(helpkeys.Favorite, "Favorite"),
+			helpkeys.WithHelp(helpkeys.Delete, "Delete"),
+			helpkeys.WithHelp(helpkeys.ManageTags, "Manage tags"),
+			helpkeys.WithHelp(helpkeys.Search, "Search"),
+			helpkeys.WithHelp(helpkeys.Download, "Download articles"),
+			helpkeys.WithHelp(helpkeys.Highlights, "Highlights"),
+			helpkeys.WithHelp(helpkeys.NextView, "Next view"),
 		}
 	}
 
@@ -178,6 +466,9 @@ func New(user models.PocketUser) Model {
 		spinner:      s,
 		user:         user,
 		errorMessage: "",
+		addForm:      newAddForm(),
+		searchForm:   newSearchForm(),
+		cursors:      make([]cursor, len(tabs)),
 	}
 }
 
@@ -185,6 +476,12 @@ type openError struct {
 	error error
 }
 
+func modifySaves(action string, saves ...models.PocketSave) tea.Cmd {
+	return func() tea.Msg {
+		return ModifySavesCmd{Action: action, Saves: saves}
+	}
+}
+
 func open(url string) tea.Cmd {
 	return func() tea.Msg {
 		err := utils.OpenInBrowser(url)
@@ -0,0 +1,157 @@
+package saves
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+	"github.com/thomas-introini/pocket-cli/db"
+	"github.com/thomas-introini/pocket-cli/models"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+type SearchSavesCmd struct {
+	Query string
+}
+
+type ReloadSavesCmd struct {
+}
+
+type searchForm struct {
+	open  bool
+	input textinput.Model
+}
+
+func newSearchForm() searchForm {
+	input := textinput.New()
+	input.Prompt = "> "
+	input.PromptStyle = styles.TitleRedStyle
+	input.Placeholder = `tag:go domain:nytimes.com is:fav "exact phrase"`
+	input.CharLimit = 256
+	return searchForm{input: input}
+}
+
+func (f *searchForm) Open(query string, width int) tea.Cmd {
+	f.open = true
+	f.input.Width = width
+	f.input.SetValue(query)
+	f.input.CursorEnd()
+	return f.input.Focus()
+}
+
+func (f *searchForm) Close() {
+	f.open = false
+	f.input.Blur()
+}
+
+func (f searchForm) Update(msg tea.Msg) (searchForm, tea.Cmd) {
+	var cmd tea.Cmd
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			f.Close()
+			return f, nil
+		case "enter":
+			f.Close()
+			query := strings.TrimSpace(f.input.Value())
+			if query == "" {
+				return f, func() tea.Msg { return ReloadSavesCmd{} }
+			}
+			return f, func() tea.Msg { return SearchSavesCmd{Query: query} }
+		}
+	}
+	f.input, cmd = f.input.Update(msg)
+	return f, cmd
+}
+
+func (f searchForm) View() string {
+	view := styles.TitleBoldRedStyle.Render("Search saves") + "\n\n"
+	view += f.input.View() + "\n\n"
+	view += styles.HintStyle.Render("filters: tag:<tag> domain:<domain> is:fav is:unread is:archived") + "\n"
+	if !db.FullTextSearch() {
+		view += styles.HintStyle.Render("full-text search is off: results are not ranked, build with -tags sqlite_fts5") + "\n"
+	}
+	view += styles.HintStyle.Render("enter search • esc cancel")
+	return styles.ModalStyle.Render(view)
+}
+
+// itemDelegate renders the search results highlighting the matched text
+type itemDelegate struct {
+	list.DefaultDelegate
+	results map[string]db.SearchResult
+}
+
+func newItemDelegate(results map[string]db.SearchResult) itemDelegate {
+	d := list.NewDefaultDelegate()
+	d.Styles.SelectedTitle = styles.SelectedItemTitleStyle
+	d.Styles.SelectedDesc = styles.SelectedItemDescriptionStyle
+	d.Styles.FilterMatch = lipgloss.NewStyle().Underline(true).Bold(true)
+	return itemDelegate{DefaultDelegate: d, results: results}
+}
+
+func (d itemDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
+	save, ok := item.(models.PocketSave)
+	result, found := d.results[save.Id]
+	if !ok || !found || m.Width() <= 0 || m.FilterState() != list.Unfiltered {
+		d.DefaultDelegate.Render(w, m, index, item)
+		return
+	}
+
+	s := &d.Styles
+	title := result.Title
+	if save.Favorite {
+		title = "★ " + title
+	}
+	if save.ReadProgress > 0 && save.ReadProgress < 100 {
+		title += " · " + strconv.Itoa(save.ReadProgress) + "%"
+	}
+	snippet := result.Snippet
+	if snippet == "" {
+		snippet = save.Description()
+	}
+	snippet = strings.Join(strings.Fields(snippet), " ")
+
+	textwidth := uint(m.Width() - s.NormalTitle.GetPaddingLeft() - s.NormalTitle.GetPaddingRight())
+	title, titleMatches := parseHighlights(title)
+	snippet, snippetMatches := parseHighlights(snippet)
+	title = truncate.StringWithTail(title, textwidth, "…")
+	snippet = truncate.StringWithTail(snippet, textwidth, "…")
+
+	titleStyle, descStyle := s.NormalTitle, s.NormalDesc
+	if index == m.Index() {
+		titleStyle, descStyle = s.SelectedTitle, s.SelectedDesc
+	}
+	title = titleStyle.Render(lipgloss.StyleRunes(title, titleMatches, titleStyle.Copy().Inline(true).Inherit(s.FilterMatch), titleStyle.Copy().Inline(true)))
+	snippet = descStyle.Render(lipgloss.StyleRunes(snippet, snippetMatches, descStyle.Copy().Inline(true).Inherit(s.FilterMatch), descStyle.Copy().Inline(true)))
+	fmt.Fprintf(w, "%s\n%s", title, snippet)
+}
+
+// parseHighlights removes the highlight markers returning the
+// indices of the highlighted runes
+func parseHighlights(text string) (string, []int) {
+	var b strings.Builder
+	matches := make([]int, 0)
+	highlighted := false
+	i := 0
+	for _, r := range text {
+		switch string(r) {
+		case db.HighlightStart:
+			highlighted = true
+		case db.HighlightEnd:
+			highlighted = false
+		default:
+			if highlighted {
+				matches = append(matches, i)
+			}
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return b.String(), matches
+}
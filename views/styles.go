@@ -1,6 +1,19 @@
 package views
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"errors"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var Themes = map[string]lipgloss.Color{
+	"red":    lipgloss.Color("#ef4056"),
+	"blue":   lipgloss.Color("#4f8ff7"),
+	"green":  lipgloss.Color("#3fb950"),
+	"purple": lipgloss.Color("#a371f7"),
+	"orange": lipgloss.Color("#f0883e"),
+}
 
 var (
 	ToolbarMessage = lipgloss.NewStyle().
@@ -8,7 +21,49 @@ var (
 			Border(lipgloss.DoubleBorder(), true).
 			Margin(0, 2, 0, 2).
 			BorderForeground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"})
+	HintStyle = lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"})
 
-	TitleRedStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("#ef4056"))
-	TitleBoldRedStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#ef4056"))
+	Accent                       lipgloss.Color
+	TitleRedStyle                lipgloss.Style
+	TitleBoldRedStyle            lipgloss.Style
+	SelectedItemTitleStyle       lipgloss.Style
+	SelectedItemDescriptionStyle lipgloss.Style
+	ModalStyle                   lipgloss.Style
 )
+
+func init() {
+	setAccent(Themes["red"])
+}
+
+func SetTheme(theme string) error {
+	if color, ok := Themes[theme]; ok {
+		setAccent(color)
+		return nil
+	}
+	if strings.HasPrefix(theme, "#") && (len(theme) == 4 || len(theme) == 7) {
+		setAccent(lipgloss.Color(theme))
+		return nil
+	}
+	return errors.New("unknown theme " + theme)
+}
+
+func setAccent(accent lipgloss.Color) {
+	Accent = accent
+	TitleRedStyle = lipgloss.NewStyle().Foreground(accent)
+	TitleBoldRedStyle = lipgloss.NewStyle().Bold(true).Foreground(accent)
+	SelectedItemTitleStyle = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(accent).
+		Foreground(accent).
+		Bold(true).
+		Padding(0, 0, 0, 1)
+	SelectedItemDescriptionStyle = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(accent).
+		Foreground(accent).
+		Padding(0, 0, 0, 1)
+	ModalStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(accent).
+		Padding(1, 2)
+}
@@ -0,0 +1,176 @@
+package tags
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thomas-introini/pocket-cli/models"
+	styles "github.com/thomas-introini/pocket-cli/views"
+)
+
+var promptStyle = lipgloss.NewStyle().PaddingLeft(2)
+
+type RenameTagCmd struct {
+	OldTag string
+	NewTag string
+}
+
+type DeleteTagCmd struct {
+	Tag string
+}
+
+type CloseCmd struct {
+}
+
+type mode int
+
+const (
+	browsing mode = iota
+	renaming
+	confirmingDelete
+)
+
+type window struct {
+	width  int
+	height int
+}
+
+type Model struct {
+	window   window
+	list     list.Model
+	input    textinput.Model
+	mode     mode
+	selected models.PocketTag
+}
+
+func New() Model {
+	id := list.NewDefaultDelegate()
+	id.Styles.SelectedTitle = styles.SelectedItemTitleStyle
+	id.Styles.SelectedDesc = styles.SelectedItemDescriptionStyle
+
+	list := list.New(make([]list.Item, 0), id, 10, 10)
+	list.DisableQuitKeybindings()
+	list.SetShowTitle(false)
+	list.SetStatusBarItemName("tag", "tags")
+	list.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{
+			key.NewBinding(
+				key.WithKeys("r"),
+				key.WithHelp("r", "Rename"),
+			),
+			key.NewBinding(
+				key.WithKeys("D"),
+				key.WithHelp("D", "Delete"),
+			),
+			key.NewBinding(
+				key.WithKeys("esc"),
+				key.WithHelp("esc", "Back"),
+			),
+		}
+	}
+
+	input := textinput.New()
+	input.Prompt = "> "
+	input.PromptStyle = styles.TitleRedStyle
+	input.CharLimit = 128
+
+	return Model{
+		list:  list,
+		input: input,
+		mode:  browsing,
+	}
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.window.width, m.window.height = msg.Width, msg.Height-3
+		m.list.SetSize(msg.Width, msg.Height-5)
+		return m, nil
+	case tea.KeyMsg:
+		switch m.mode {
+		case renaming:
+			switch msg.String() {
+			case "esc":
+				m.mode = browsing
+				m.input.Blur()
+			case "enter":
+				m.mode = browsing
+				m.input.Blur()
+				newTag := strings.TrimSpace(m.input.Value())
+				if newTag != "" && newTag != m.selected.Name {
+					oldTag := m.selected.Name
+					return m, func() tea.Msg {
+						return RenameTagCmd{OldTag: oldTag, NewTag: newTag}
+					}
+				}
+			default:
+				m.input, cmd = m.input.Update(msg)
+			}
+			return m, cmd
+		case confirmingDelete:
+			m.mode = browsing
+			if msg.String() == "y" {
+				tag := m.selected.Name
+				return m, func() tea.Msg {
+					return DeleteTagCmd{Tag: tag}
+				}
+			}
+			return m, nil
+		}
+		if m.list.FilterState() != list.Filtering {
+			selected, ok := m.list.SelectedItem().(models.PocketTag)
+			switch msg.String() {
+			case "esc":
+				if m.list.FilterState() == list.Unfiltered {
+					return m, func() tea.Msg { return CloseCmd{} }
+				}
+			case "r":
+				if ok {
+					m.selected = selected
+					m.mode = renaming
+					m.input.SetValue(selected.Name)
+					m.input.CursorEnd()
+					return m, m.input.Focus()
+				}
+			case "D":
+				if ok {
+					m.selected = selected
+					m.mode = confirmingDelete
+					return m, nil
+				}
+			}
+		}
+	}
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m Model) View() string {
+	view := m.list.View() + "\n"
+	switch m.mode {
+	case renaming:
+		view += promptStyle.Render(styles.TitleBoldRedStyle.Render("Rename #"+m.selected.Name+" to:") + " " + m.input.View())
+	case confirmingDelete:
+		view += promptStyle.Render(styles.TitleBoldRedStyle.Render("Delete #"+m.selected.Name+" from "+m.selected.Description()+"?") + " " + styles.TitleRedStyle.Render("(y/n)"))
+	}
+	return view
+}
+
+func (m *Model) SetTags(tags []models.PocketTag) {
+	items := make([]list.Item, 0, len(tags))
+	for _, t := range tags {
+		items = append(items, t)
+	}
+	m.list.SetItems(items)
+}
@@ -1,6 +1,10 @@
 package titlebar
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	styles "github.com/thomas-introini/pocket-cli/views"
@@ -13,16 +17,24 @@ type window struct {
 }
 
 type Model struct {
-	user    string
-	window  window
-	message spinnerlabel.Model
+	user        string
+	window      window
+	message     spinnerlabel.Model
+	tabs        []string
+	active      int
+	status      string
+	lastSync    time.Time
+	syncSummary string
+	// readProgress is the progress in the article being read, hidden when negative
+	readProgress int
 }
 
 func New(user, title string) Model {
 	return Model{
-		user:    user,
-		window:  window{},
-		message: spinnerlabel.New("", title),
+		user:         user,
+		window:       window{},
+		message:      spinnerlabel.New("", title),
+		readProgress: -1,
 	}
 }
 
@@ -44,8 +56,77 @@ func (m Model) View() string {
 	var msg = m.message.View()
 	toolbarMaxWidth := m.window.width - 5
 	toolbarUser := lipgloss.NewStyle().MarginRight(1).Render(m.user)
-	toolbarMessage := lipgloss.NewStyle().MarginLeft(1).Width(toolbarMaxWidth - 1 - lipgloss.Width(toolbarUser)).Render(msg)
-	return styles.ToolbarMessage.Width(toolbarMaxWidth).Render(toolbarMessage+toolbarUser) + "\n"
+	toolbarTabs := m.tabsView()
+	toolbarStatus := ""
+	if m.readProgress >= 0 {
+		toolbarStatus += lipgloss.NewStyle().MarginRight(2).Render(progressBar(m.readProgress))
+	}
+	if m.status != "" {
+		toolbarStatus += styles.TitleRedStyle.Copy().MarginRight(2).Render(m.status)
+	}
+	if !m.lastSync.IsZero() {
+		synced := "synced " + ago(m.lastSync)
+		if m.syncSummary != "" {
+			synced += " · " + m.syncSummary
+		}
+		toolbarStatus += styles.HintStyle.Copy().MarginRight(2).Render(synced)
+	}
+	toolbarMessage := lipgloss.NewStyle().MarginLeft(1).Width(toolbarMaxWidth - 1 - lipgloss.Width(toolbarUser) - lipgloss.Width(toolbarTabs) - lipgloss.Width(toolbarStatus)).Render(msg)
+	return styles.ToolbarMessage.Width(toolbarMaxWidth).Render(toolbarMessage+toolbarStatus+toolbarTabs+toolbarUser) + "\n"
+}
+
+func (m Model) tabsView() string {
+	if len(m.tabs) == 0 {
+		return ""
+	}
+	tabs := make([]string, len(m.tabs))
+	for i, tab := range m.tabs {
+		if i == m.active {
+			tabs[i] = styles.TitleBoldRedStyle.Render(tab)
+		} else {
+			tabs[i] = styles.HintStyle.Render(tab)
+		}
+	}
+	return lipgloss.NewStyle().MarginRight(2).Render(strings.Join(tabs, styles.HintStyle.Render(" │ ")))
+}
+
+func (m *Model) SetLastSync(t time.Time, summary string) {
+	m.lastSync = t
+	m.syncSummary = summary
+}
+
+// progressBar renders the percent as "━━━━────── 42%"
+func progressBar(percent int) string {
+	const width = 10
+	done := percent * width / 100
+	bar := styles.TitleRedStyle.Render(strings.Repeat("━", done)) + styles.HintStyle.Render(strings.Repeat("─", width-done))
+	return bar + " " + styles.TitleRedStyle.Render(fmt.Sprintf("%d%%", percent))
+}
+
+func ago(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d min ago", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%d h ago", int(d.Hours()))
+	}
+}
+
+// SetReadProgress shows the progress in the article being read, a negative one hides it
+func (m *Model) SetReadProgress(percent int) {
+	m.readProgress = min(percent, 100)
+}
+
+func (m *Model) SetStatus(status string) {
+	m.status = status
+}
+
+func (m *Model) SetTabs(tabs []string, active int) {
+	m.tabs = tabs
+	m.active = active
 }
 
 func (m *Model) ShowMessage(msg string) {